@@ -20,14 +20,168 @@
 
 // Package automaxprocs automatically sets GOMAXPROCS to match the Linux
 // container CPU quota, if any.
+//
+// Reading the CPU quota gives up after a second, so that a hung `/proc` or
+// cgroup file system doesn't block program startup.
+//
+// Set AUTOMAXPROCS_STRICT=true to make the process exit at startup if the CPU
+// quota can't be determined, or if one of the following variables is invalid.
+// See maxprocs.Strict for details. Otherwise, invalid variables are logged and
+// left at their defaults.
+//
+// Set AUTOMAXPROCS_RUNTIME to override, defer or manage to select how to
+// coordinate with Go 1.25 and later runtimes, which set GOMAXPROCS from the
+// CPU limit by themselves. See maxprocs.RuntimePolicy for details. The
+// default is override.
+//
+// Set AUTOMAXPROCS_ROUNDING to a rounding strategy such as ceil or
+// ceil-above:0.75 to change how the CPU quota is rounded to GOMAXPROCS. See
+// maxprocs.ParseRounding for the accepted values. The default is floor.
+//
+// Set AUTOMAXPROCS_CONFIG to the path of a JSON policy file to adjust
+// GOMAXPROCS with rules over the detected CPU and memory limits. See
+// maxprocs.Policy for the format. Like the other variables, a missing or
+// invalid file is logged and ignored unless AUTOMAXPROCS_STRICT=true.
 package automaxprocs // import "go.uber.org/automaxprocs"
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"go.uber.org/automaxprocs/maxprocs"
 )
 
+const (
+	_strictKey   = "AUTOMAXPROCS_STRICT"
+	_runtimeKey  = "AUTOMAXPROCS_RUNTIME"
+	_roundingKey = "AUTOMAXPROCS_ROUNDING"
+	_configKey   = "AUTOMAXPROCS_CONFIG"
+
+	// _timeout bounds reads of the CPU quota, which normally take well under
+	// a millisecond.
+	_timeout = time.Second
+)
+
 func init() {
-	maxprocs.Set(maxprocs.Logger(log.Printf))
+	env, errs := configFromEnv()
+	for _, err := range errs {
+		if env.strict {
+			log.Fatal(err)
+		}
+		log.Printf("%v; ignoring it", err)
+	}
+	opts := []maxprocs.Option{maxprocs.Logger(log.Printf), maxprocs.Timeout(_timeout)}
+	_, err := maxprocs.Set(append(opts, env.options()...)...)
+	if err != nil && env.strict {
+		log.Fatal(err)
+	}
+}
+
+// envConfig is what the AUTOMAXPROCS_* environment variables ask for.
+type envConfig struct {
+	strict  bool
+	runtime maxprocs.RuntimePolicy
+	// rounding is nil unless AUTOMAXPROCS_ROUNDING is set.
+	rounding *maxprocs.RoundingStrategy
+	// noPolicy is set if the policy file in AUTOMAXPROCS_CONFIG is invalid.
+	noPolicy bool
+}
+
+// configFromEnv reads the AUTOMAXPROCS_* environment variables. Invalid
+// variables are left at their defaults, and reported in errs.
+func configFromEnv() (env envConfig, errs []error) {
+	var err error
+	if env.strict, err = strictFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if env.runtime, err = runtimePolicyFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if env.rounding, err = roundingFromEnv(); err != nil {
+		errs = append(errs, err)
+	}
+	if err = policyFromEnv(); err != nil {
+		env.noPolicy = true
+		errs = append(errs, err)
+	}
+	return env, errs
+}
+
+// options returns the maxprocs options for env.
+func (env envConfig) options() []maxprocs.Option {
+	opts := []maxprocs.Option{maxprocs.Runtime(env.runtime)}
+	if env.strict {
+		opts = append(opts, maxprocs.Strict())
+	}
+	if env.rounding != nil {
+		opts = append(opts, maxprocs.Rounding(*env.rounding))
+	}
+	if env.noPolicy {
+		opts = append(opts, maxprocs.PolicyFile(""))
+	}
+	return opts
+}
+
+// strictFromEnv reports whether AUTOMAXPROCS_STRICT asks for strict mode.
+func strictFromEnv() (bool, error) {
+	v, ok := os.LookupEnv(_strictKey)
+	if !ok || v == "" {
+		return false, nil
+	}
+	strict, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("maxprocs: invalid %v=%q: must be a boolean", _strictKey, v)
+	}
+	return strict, nil
+}
+
+var _runtimePolicies = map[string]maxprocs.RuntimePolicy{
+	"override": maxprocs.RuntimeOverride,
+	"defer":    maxprocs.RuntimeDefer,
+	"manage":   maxprocs.RuntimeManage,
+}
+
+// runtimePolicyFromEnv returns the RuntimePolicy AUTOMAXPROCS_RUNTIME asks
+// for.
+func runtimePolicyFromEnv() (maxprocs.RuntimePolicy, error) {
+	v, ok := os.LookupEnv(_runtimeKey)
+	if !ok || v == "" {
+		return maxprocs.RuntimeOverride, nil
+	}
+	policy, ok := _runtimePolicies[v]
+	if !ok {
+		return maxprocs.RuntimeOverride, fmt.Errorf("maxprocs: invalid %v=%q: must be override, defer or manage", _runtimeKey, v)
+	}
+	return policy, nil
+}
+
+// roundingFromEnv returns the RoundingStrategy AUTOMAXPROCS_ROUNDING asks
+// for, or nil if it's unset.
+func roundingFromEnv() (*maxprocs.RoundingStrategy, error) {
+	v := os.Getenv(_roundingKey)
+	if v == "" {
+		return nil, nil
+	}
+	rounding, err := maxprocs.ParseRounding(v)
+	if err != nil {
+		return nil, fmt.Errorf("maxprocs: invalid %v: %w", _roundingKey, err)
+	}
+	return &rounding, nil
+}
+
+// policyFromEnv checks that the policy file in AUTOMAXPROCS_CONFIG, if any,
+// is valid. Set handles missing files itself.
+func policyFromEnv() error {
+	path := os.Getenv(_configKey)
+	if path == "" {
+		return nil
+	}
+	if _, err := maxprocs.LoadPolicy(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
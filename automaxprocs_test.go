@@ -0,0 +1,189 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package automaxprocs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/automaxprocs/maxprocs"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		strict       string
+		runtime      string
+		rounding     string
+		want         envConfig
+		wantRounding string
+		wantOptions  int
+		wantErrs     []string
+	}{
+		{
+			name:        "unset",
+			want:        envConfig{runtime: maxprocs.RuntimeOverride},
+			wantOptions: 1,
+		},
+		{
+			name:        "strict",
+			strict:      "true",
+			want:        envConfig{strict: true, runtime: maxprocs.RuntimeOverride},
+			wantOptions: 2,
+		},
+		{
+			name:        "not strict",
+			strict:      "0",
+			want:        envConfig{runtime: maxprocs.RuntimeOverride},
+			wantOptions: 1,
+		},
+		{
+			name:        "invalid strict",
+			strict:      "yes",
+			want:        envConfig{runtime: maxprocs.RuntimeOverride},
+			wantOptions: 1,
+			wantErrs:    []string{`maxprocs: invalid AUTOMAXPROCS_STRICT="yes": must be a boolean`},
+		},
+		{
+			name:        "runtime",
+			runtime:     "manage",
+			want:        envConfig{runtime: maxprocs.RuntimeManage},
+			wantOptions: 1,
+		},
+		{
+			name:        "invalid runtime",
+			runtime:     "ignore",
+			want:        envConfig{runtime: maxprocs.RuntimeOverride},
+			wantOptions: 1,
+			wantErrs:    []string{`maxprocs: invalid AUTOMAXPROCS_RUNTIME="ignore": must be override, defer or manage`},
+		},
+		{
+			name:         "rounding",
+			rounding:     "ceil-above:0.75",
+			want:         envConfig{runtime: maxprocs.RuntimeOverride},
+			wantRounding: "ceil-above:0.75",
+			wantOptions:  2,
+		},
+		{
+			name:        "invalid rounding",
+			rounding:    "up",
+			want:        envConfig{runtime: maxprocs.RuntimeOverride},
+			wantOptions: 1,
+			wantErrs:    []string{"maxprocs: invalid AUTOMAXPROCS_ROUNDING:"},
+		},
+		{
+			name:         "invalid with strict",
+			strict:       "true",
+			runtime:      "ignore",
+			rounding:     "ceil",
+			want:         envConfig{strict: true, runtime: maxprocs.RuntimeOverride},
+			wantOptions:  3,
+			wantRounding: "ceil",
+			wantErrs:     []string{`maxprocs: invalid AUTOMAXPROCS_RUNTIME="ignore"`},
+		},
+		{
+			name:        "several invalid",
+			strict:      "yes",
+			runtime:     "manage",
+			rounding:    "up",
+			want:        envConfig{runtime: maxprocs.RuntimeManage},
+			wantOptions: 1,
+			wantErrs:    []string{"maxprocs: invalid AUTOMAXPROCS_STRICT=", "maxprocs: invalid AUTOMAXPROCS_ROUNDING:"},
+		},
+		{
+			name:         "all",
+			strict:       "true",
+			runtime:      "defer",
+			rounding:     "ceil",
+			want:         envConfig{strict: true, runtime: maxprocs.RuntimeDefer},
+			wantRounding: "ceil",
+			wantOptions:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(_strictKey, tt.strict)
+			t.Setenv(_runtimeKey, tt.runtime)
+			t.Setenv(_roundingKey, tt.rounding)
+			t.Setenv(_configKey, "")
+
+			env, errs := configFromEnv()
+			require.Len(t, errs, len(tt.wantErrs), "unexpected errors: %v", errs)
+			for i, err := range errs {
+				assert.Contains(t, err.Error(), tt.wantErrs[i])
+			}
+			assert.Len(t, env.options(), tt.wantOptions)
+
+			if tt.wantRounding != "" {
+				require.NotNil(t, env.rounding, "should parse the rounding strategy")
+				assert.Equal(t, tt.wantRounding, env.rounding.String())
+			} else {
+				assert.Nil(t, env.rounding, "shouldn't round without AUTOMAXPROCS_ROUNDING")
+			}
+			env.rounding = nil
+			assert.Equal(t, tt.want, env)
+		})
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"rules": [{"max": 3}]}`), 0o600))
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"rules": [{"max": "three"}]}`), 0o600))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "unset"},
+		{name: "valid", path: valid},
+		{name: "missing", path: filepath.Join(dir, "missing.json")},
+		{name: "invalid", path: invalid, wantErr: "maxprocs: invalid policy " + invalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(_strictKey, "")
+			t.Setenv(_runtimeKey, "")
+			t.Setenv(_roundingKey, "")
+			t.Setenv(_configKey, tt.path)
+
+			env, errs := configFromEnv()
+			if tt.wantErr == "" {
+				assert.Empty(t, errs)
+				assert.False(t, env.noPolicy, "should load the policy")
+				assert.Len(t, env.options(), 1)
+				return
+			}
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0].Error(), tt.wantErr)
+			assert.True(t, env.noPolicy, "should ignore the invalid policy")
+			assert.Len(t, env.options(), 2, "should disable the policy")
+		})
+	}
+}
@@ -66,7 +66,7 @@ func (cg *CGroup) readFirstLine(param string) (string, error) {
 	if err := scanner.Err(); err != nil {
 		return "", err
 	}
-	return "", io.ErrUnexpectedEOF
+	return "", &ParseError{Path: cg.ParamPath(param), Line: 1, Err: invalidFormat(param, io.ErrUnexpectedEOF)}
 }
 
 // readInt parses the first line from a cgroup param file as int.
@@ -75,5 +75,9 @@ func (cg *CGroup) readInt(param string) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	return strconv.Atoi(text)
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, &ParseError{Path: cg.ParamPath(param), Line: 1, Content: text, Err: invalidFormat(param, err)}
+	}
+	return n, nil
 }
@@ -23,6 +23,15 @@
 
 package cgroups
 
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
 const (
 	// _cgroupFSType is the Linux CGroup file system type used in
 	// `/proc/$PID/mountinfo`.
@@ -35,6 +44,8 @@ const (
 	_cgroupSubsysCPUSet = "cpuset"
 	// _cgroupSubsysMemory is the Memory CGroup subsystem.
 	_cgroupSubsysMemory = "memory"
+	// _cgroupSubsysPids is the process number CGroup subsystem.
+	_cgroupSubsysPids = "pids"
 
 	// _cgroupCPUCFSQuotaUsParam is the file name for the CGroup CFS quota
 	// parameter.
@@ -42,6 +53,36 @@ const (
 	// _cgroupCPUCFSPeriodUsParam is the file name for the CGroup CFS period
 	// parameter.
 	_cgroupCPUCFSPeriodUsParam = "cpu.cfs_period_us"
+	// _cgroupCPUSharesParam is the file name for the CGroup CPU shares
+	// parameter.
+	_cgroupCPUSharesParam = "cpu.shares"
+	// _cgroupPidsMaxParam is the file name for the CGroup pids limit
+	// parameter. It has the same name in cgroups v1 and v2.
+	_cgroupPidsMaxParam = "pids.max"
+	// _cgroupPidsMaxUnlimited is the value of _cgroupPidsMaxParam when the
+	// number of tasks is not limited.
+	_cgroupPidsMaxUnlimited = "max"
+	// _cgroupCPUSetCPUsParam is the file name for the CGroup cpuset CPUs
+	// parameter.
+	_cgroupCPUSetCPUsParam = "cpuset.cpus"
+	// _cgroupMemoryLimitParam is the file name for the CGroup memory limit
+	// parameter.
+	_cgroupMemoryLimitParam = "memory.limit_in_bytes"
+	// _cgroupMemoryUnlimited is the smallest value of _cgroupMemoryLimitParam
+	// that means the memory is not limited. The kernel reports no limit as
+	// the largest int64 rounded down to the page size.
+	_cgroupMemoryUnlimited = 1 << 62
+)
+
+const (
+	// _cgroupCPUSharesPerCPU is the number of CPU shares Kubernetes assigns
+	// per requested CPU.
+	_cgroupCPUSharesPerCPU = 1024
+	// _cgroupCPUSharesMin is the minimum number of CPU shares, which
+	// Kubernetes assigns to containers without a CPU request.
+	_cgroupCPUSharesMin = 2
+	// _cgroupCPUSharesMax is the maximum number of CPU shares.
+	_cgroupCPUSharesMax = 262144
 )
 
 const (
@@ -55,38 +96,12 @@ type CGroups map[string]*CGroup
 // NewCGroups returns a new *CGroups from given `mountinfo` and `cgroup` files
 // under for some process under `/proc` file system (see also proc(5) for more
 // information).
-func NewCGroups(procPathMountInfo, procPathCGroup string) (CGroups, error) {
-	cgroupSubsystems, err := parseCGroupSubsystems(procPathCGroup)
+func NewCGroups(procPathMountInfo, procPathCGroup string, opts ...Option) (CGroups, error) {
+	snapshot, err := NewSnapshot(procPathMountInfo, procPathCGroup, opts...)
 	if err != nil {
 		return nil, err
 	}
-
-	cgroups := make(CGroups)
-	newMountPoint := func(mp *MountPoint) error {
-		if mp.FSType != _cgroupFSType {
-			return nil
-		}
-
-		for _, opt := range mp.SuperOptions {
-			subsys, exists := cgroupSubsystems[opt]
-			if !exists {
-				continue
-			}
-
-			cgroupPath, err := mp.Translate(subsys.Name)
-			if err != nil {
-				return err
-			}
-			cgroups[opt] = NewCGroup(cgroupPath)
-		}
-
-		return nil
-	}
-
-	if err := parseMountInfo(procPathMountInfo, newMountPoint); err != nil {
-		return nil, err
-	}
-	return cgroups, nil
+	return snapshot.CGroups()
 }
 
 // NewCGroupsForCurrentProcess returns a new *CGroups instance for the current
@@ -95,6 +110,20 @@ func NewCGroupsForCurrentProcess() (CGroups, error) {
 	return NewCGroups(_procPathMountInfo, _procPathCGroup)
 }
 
+// NewCGroupsWithRoot returns a new *CGroups instance for the current process,
+// resolving the `/proc` and cgroup file system paths against root rather than
+// against `/`.
+func NewCGroupsWithRoot(root string, opts ...Option) (CGroups, error) {
+	snapshot, err := NewSnapshotWithRoot(root, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.CGroups()
+}
+
+// Version returns 1, the version of the cgroups.
+func (cg CGroups) Version() int { return 1 }
+
 // CPUQuota returns the CPU quota applied with the CPU cgroup controller.
 // It is a result of `cpu.cfs_quota_us / cpu.cfs_period_us`. If the value of
 // `cpu.cfs_quota_us` was not set (-1), the method returns `(-1, nil)`.
@@ -116,3 +145,203 @@ func (cg CGroups) CPUQuota() (float64, bool, error) {
 
 	return float64(cfsQuotaUs) / float64(cfsPeriodUs), true, nil
 }
+
+// CPUPeriod returns the CFS period of the CPU cgroup controller, in
+// microseconds, from `cpu.cfs_period_us`. If the controller isn't mounted, or
+// the file is absent, the method returns `(-1, false, nil)`.
+func (cg CGroups) CPUPeriod() (int, bool, error) {
+	cpuCGroup, exists := cg[_cgroupSubsysCPU]
+	if !exists {
+		return -1, false, nil
+	}
+
+	period, err := cpuCGroup.readInt(_cgroupCPUCFSPeriodUsParam)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	if period <= 0 {
+		return -1, false, nil
+	}
+	return period, true, nil
+}
+
+// Paths returns the paths of the cgroups, by subsystem name.
+func (cg CGroups) Paths() map[string]string {
+	paths := make(map[string]string, len(cg))
+	for subsys, cgroup := range cg {
+		paths[subsys] = cgroup.Path()
+	}
+	return paths
+}
+
+// CPURequest returns the CPU request of the cgroup, in CPUs, derived from
+// `cpu.shares` the way Kubernetes sets it: 1024 shares per CPU. If the shares
+// are absent or at their minimum, as for pods without a CPU request, the
+// method returns `(-1, false, nil)`.
+func (cg CGroups) CPURequest() (float64, bool, error) {
+	cpuCGroup, exists := cg[_cgroupSubsysCPU]
+	if !exists {
+		return -1, false, nil
+	}
+
+	shares, err := cpuCGroup.readInt(_cgroupCPUSharesParam)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	return sharesToCPURequest(float64(shares))
+}
+
+// sharesToCPURequest converts CPU shares to a CPU request.
+func sharesToCPURequest(shares float64) (float64, bool, error) {
+	if shares <= _cgroupCPUSharesMin {
+		return -1, false, nil
+	}
+	return shares / _cgroupCPUSharesPerCPU, true, nil
+}
+
+// PidsMax returns the maximum number of tasks allowed by the pids cgroup
+// controller. If the controller isn't mounted, or `pids.max` is absent or set
+// to "max", the method returns `(-1, false, nil)`.
+func (cg CGroups) PidsMax() (int, bool, error) {
+	pidsCGroup, exists := cg[_cgroupSubsysPids]
+	if !exists {
+		return -1, false, nil
+	}
+
+	return pidsCGroup.readPidsMax()
+}
+
+// CPUSetSize returns the number of CPUs in the cpuset of the cgroup. If the
+// cpuset controller isn't mounted, or `cpuset.cpus` is absent or empty, the
+// method returns `(-1, false, nil)`.
+func (cg CGroups) CPUSetSize() (int, bool, error) {
+	cpusetCGroup, exists := cg[_cgroupSubsysCPUSet]
+	if !exists {
+		return -1, false, nil
+	}
+	return cpusetCGroup.readCPUSetSize(_cgroupCPUSetCPUsParam)
+}
+
+// MemoryLimit returns the memory limit of the cgroup, in bytes, from
+// `memory.limit_in_bytes`. If the memory controller isn't mounted, or the file
+// is absent or set to no limit, the method returns `(-1, false, nil)`.
+func (cg CGroups) MemoryLimit() (int64, bool, error) {
+	memoryCGroup, exists := cg[_cgroupSubsysMemory]
+	if !exists {
+		return -1, false, nil
+	}
+	return memoryCGroup.readMemoryLimit(_cgroupMemoryLimitParam)
+}
+
+// readCPUSetSize reads a cpuset CPU list file of a cgroup, such as
+// `cpuset.cpus`, and returns the number of CPUs in it. If it's absent or
+// empty, it returns (-1, false, nil).
+func (cg *CGroup) readCPUSetSize(param string) (int, bool, error) {
+	text, err := cg.readFirstLine(param)
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	if text == "" {
+		return -1, false, nil
+	}
+
+	n, err := ParseCPUList(text)
+	if err != nil {
+		return -1, false, &ParseError{
+			Path:    cg.ParamPath(param),
+			Line:    1,
+			Content: text,
+			Err:     invalidFormat(param, err),
+		}
+	}
+	return n, true, nil
+}
+
+// ParseCPUList returns the number of CPUs in a list such as "0-3,8", in the
+// format of `cpuset.cpus` and `/sys/devices/system/cpu/online`. See also
+// cpuset(7).
+func ParseCPUList(text string) (int, error) {
+	n := 0
+	for _, r := range strings.Split(text, ",") {
+		first, last, isRange := strings.Cut(r, "-")
+		lo, err := strconv.Atoi(first)
+		if err != nil {
+			return 0, err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil {
+				return 0, err
+			}
+		}
+		if lo < 0 || hi < lo {
+			return 0, fmt.Errorf("invalid CPU range %q", r)
+		}
+		n += hi - lo + 1
+	}
+	return n, nil
+}
+
+// readPidsMax reads the `pids.max` file of a cgroup. If it's absent or set to
+// "max", it returns (-1, false, nil).
+func (cg *CGroup) readPidsMax() (int, bool, error) {
+	text, err := cg.readFirstLine(_cgroupPidsMaxParam)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	if text == _cgroupPidsMaxUnlimited {
+		return -1, false, nil
+	}
+
+	pidsMax, err := strconv.Atoi(text)
+	if err != nil {
+		return -1, false, &ParseError{
+			Path:    cg.ParamPath(_cgroupPidsMaxParam),
+			Line:    1,
+			Content: text,
+			Err:     invalidFormat(_cgroupPidsMaxParam, err),
+		}
+	}
+	return pidsMax, true, nil
+}
+
+// readMemoryLimit reads a memory limit file of a cgroup, such as `memory.max`.
+// If it's absent or set to no limit, it returns (-1, false, nil).
+func (cg *CGroup) readMemoryLimit(param string) (int64, bool, error) {
+	text, err := cg.readFirstLine(param)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	if text == _cgroupV2MemoryMaxUnlimited {
+		return -1, false, nil
+	}
+
+	limit, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return -1, false, &ParseError{
+			Path:    cg.ParamPath(param),
+			Line:    1,
+			Content: text,
+			Err:     invalidFormat(param, err),
+		}
+	}
+	if limit >= _cgroupMemoryUnlimited {
+		return -1, false, nil
+	}
+	return limit, true, nil
+}
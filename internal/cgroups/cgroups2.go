@@ -28,6 +28,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"path"
 	"strconv"
@@ -42,10 +43,26 @@ const (
 	// `/proc/$PID/mountinfo`.
 	_cgroupv2FSType = "cgroup2"
 
+	// _cgroupv2CPUWeight is the file name for the CGroup-V2 CPU weight
+	// parameter.
+	_cgroupv2CPUWeight = "cpu.weight"
+
 	_cgroupv2MountPoint = "/sys/fs/cgroup"
 
 	_cgroupV2CPUMaxDefaultPeriod = 100000
 	_cgroupV2CPUMaxQuotaMax      = "max"
+
+	// _cgroupv2CPUSetCPUsEffective is the file name for the CPUs the cgroup
+	// may actually use, which accounts for the cpusets of its ancestors.
+	_cgroupv2CPUSetCPUsEffective = "cpuset.cpus.effective"
+
+	// _cgroupv2MemoryMax is the file name for the CGroup-V2 memory limit
+	// parameter, which is _cgroupV2MemoryMaxUnlimited without a limit.
+	_cgroupv2MemoryMax          = "memory.max"
+	_cgroupV2MemoryMaxUnlimited = "max"
+
+	_cgroupV2CPUWeightMin = 1
+	_cgroupV2CPUWeightMax = 10000
 )
 
 const (
@@ -70,63 +87,36 @@ func NewCGroups2ForCurrentProcess() (*CGroups2, error) {
 	return newCGroups2From(_procPathMountInfo, _procPathCGroup)
 }
 
-func newCGroups2From(mountInfoPath, procPathCGroup string) (*CGroups2, error) {
-	isV2, err := isCGroupV2(mountInfoPath)
+// NewCGroups2WithRoot builds a CGroups2 for the current process, resolving the
+// `/proc` and cgroup file system paths against root rather than against `/`.
+//
+// This returns ErrNotV2 if the system is not using cgroups2.
+func NewCGroups2WithRoot(root string, opts ...Option) (*CGroups2, error) {
+	snapshot, err := NewSnapshotWithRoot(root, opts...)
 	if err != nil {
 		return nil, err
 	}
+	return snapshot.CGroups2()
+}
 
-	if !isV2 {
-		return nil, ErrNotV2
-	}
-
-	subsystems, err := parseCGroupSubsystems(procPathCGroup)
+func newCGroups2From(mountInfoPath, procPathCGroup string, opts ...Option) (*CGroups2, error) {
+	snapshot, err := NewSnapshot(mountInfoPath, procPathCGroup, opts...)
 	if err != nil {
 		return nil, err
 	}
-
-	// Find v2 subsystem by looking for the `0` id
-	var v2subsys *CGroupSubsys
-	for _, subsys := range subsystems {
-		if subsys.ID == 0 {
-			v2subsys = subsys
-			break
-		}
-	}
-
-	if v2subsys == nil {
-		return nil, ErrNotV2
-	}
-
-	return &CGroups2{
-		mountPoint: _cgroupv2MountPoint,
-		groupPath:  v2subsys.Name,
-		cpuMaxFile: _cgroupv2CPUMax,
-	}, nil
+	return snapshot.CGroups2()
 }
 
-func isCGroupV2(procPathMountInfo string) (bool, error) {
-	var (
-		isV2          bool
-		newMountPoint = func(mp *MountPoint) error {
-			isV2 = isV2 || (mp.FSType == _cgroupv2FSType && mp.MountPoint == _cgroupv2MountPoint)
-			return nil
-		}
-	)
-
-	if err := parseMountInfo(procPathMountInfo, newMountPoint); err != nil {
-		return false, err
-	}
-
-	return isV2, nil
-}
+// Version returns 2, the version of the cgroups.
+func (cg *CGroups2) Version() int { return 2 }
 
 // CPUQuota returns the CPU quota applied with the CPU cgroup2 controller.
 // It is a result of reading cpu quota and period from cpu.max file.
 // It will return `cpu.max / cpu.period`. If cpu.max is set to max, it returns
 // (-1, false, nil)
 func (cg *CGroups2) CPUQuota() (float64, bool, error) {
-	cpuMaxParams, err := os.Open(path.Join(cg.mountPoint, cg.groupPath, cg.cpuMaxFile))
+	cpuMaxPath := path.Join(cg.mountPoint, cg.groupPath, cg.cpuMaxFile)
+	cpuMaxParams, err := os.Open(cpuMaxPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return -1, false, nil
@@ -137,9 +127,14 @@ func (cg *CGroups2) CPUQuota() (float64, bool, error) {
 
 	scanner := bufio.NewScanner(cpuMaxParams)
 	if scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+		line := scanner.Text()
+		newParseError := func(err error) error {
+			return &ParseError{Path: cpuMaxPath, Line: 1, Content: line, Err: err}
+		}
+
+		fields := strings.Fields(line)
 		if len(fields) == 0 || len(fields) > 2 {
-			return -1, false, fmt.Errorf("invalid format")
+			return -1, false, newParseError(invalidFormat(cg.cpuMaxFile, nil))
 		}
 
 		if fields[_cgroupv2CPUMaxQuotaIndex] == _cgroupV2CPUMaxQuotaMax {
@@ -148,7 +143,7 @@ func (cg *CGroups2) CPUQuota() (float64, bool, error) {
 
 		max, err := strconv.Atoi(fields[_cgroupv2CPUMaxQuotaIndex])
 		if err != nil {
-			return -1, false, err
+			return -1, false, newParseError(invalidFormat(cg.cpuMaxFile, err))
 		}
 
 		var period int
@@ -157,11 +152,11 @@ func (cg *CGroups2) CPUQuota() (float64, bool, error) {
 		} else {
 			period, err = strconv.Atoi(fields[_cgroupv2CPUMaxPeriodIndex])
 			if err != nil {
-				return -1, false, err
+				return -1, false, newParseError(invalidFormat(cg.cpuMaxFile, err))
 			}
 
 			if period == 0 {
-				return -1, false, errors.New("zero value for period is not allowed")
+				return -1, false, newParseError(fmt.Errorf("%w: zero value for period is not allowed", ErrInvalidValue))
 			}
 		}
 
@@ -172,5 +167,90 @@ func (cg *CGroups2) CPUQuota() (float64, bool, error) {
 		return -1, false, err
 	}
 
-	return 0, false, io.ErrUnexpectedEOF
+	return 0, false, &ParseError{Path: cpuMaxPath, Line: 1, Err: invalidFormat(cg.cpuMaxFile, io.ErrUnexpectedEOF)}
+}
+
+// CPUPeriod returns the CPU period of the cgroup, in microseconds, from
+// `cpu.max`, which defaults to 100000 if the file only holds the quota. If the
+// file is absent, it returns (-1, false, nil).
+func (cg *CGroups2) CPUPeriod() (int, bool, error) {
+	cgroup := NewCGroup(path.Join(cg.mountPoint, cg.groupPath))
+	line, err := cgroup.readFirstLine(cg.cpuMaxFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	newParseError := func(err error) error {
+		return &ParseError{Path: cgroup.ParamPath(cg.cpuMaxFile), Line: 1, Content: line, Err: err}
+	}
+
+	fields := strings.Fields(line)
+	switch len(fields) {
+	case 1:
+		return _cgroupV2CPUMaxDefaultPeriod, true, nil
+	case 2:
+	default:
+		return -1, false, newParseError(invalidFormat(cg.cpuMaxFile, nil))
+	}
+	period, err := strconv.Atoi(fields[_cgroupv2CPUMaxPeriodIndex])
+	if err != nil {
+		return -1, false, newParseError(invalidFormat(cg.cpuMaxFile, err))
+	}
+	if period == 0 {
+		return -1, false, newParseError(fmt.Errorf("%w: zero value for period is not allowed", ErrInvalidValue))
+	}
+	return period, true, nil
+}
+
+// Paths returns the path of the cgroup, by the name of its file system type,
+// "cgroup2".
+func (cg *CGroups2) Paths() map[string]string {
+	return map[string]string{_cgroupv2FSType: path.Join(cg.mountPoint, cg.groupPath)}
+}
+
+// CPURequest returns the CPU request of the cgroup, in CPUs, derived from
+// `cpu.weight`. The weight is converted back to CPU shares by inverting the
+// conversion Kubernetes uses on cgroups v2, taking the largest number of shares
+// that maps to the weight, so whole CPU requests survive the round trip. If the
+// weight is absent or at its minimum, as for pods without a CPU request, the
+// method returns (-1, false, nil).
+func (cg *CGroups2) CPURequest() (float64, bool, error) {
+	weight, err := NewCGroup(path.Join(cg.mountPoint, cg.groupPath)).readInt(_cgroupv2CPUWeight)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, false, nil
+		}
+		return -1, false, err
+	}
+	if weight <= _cgroupV2CPUWeightMin {
+		return -1, false, nil
+	}
+
+	// Kubernetes computes weight = 1 + ((shares - 2) * 9999) / 262142.
+	const sharesPerWeight = float64(_cgroupCPUSharesMax-_cgroupCPUSharesMin) / (_cgroupV2CPUWeightMax - _cgroupV2CPUWeightMin)
+	shares := math.Ceil(_cgroupCPUSharesMin+float64(weight)*sharesPerWeight) - 1
+	return sharesToCPURequest(shares)
+}
+
+// PidsMax returns the maximum number of tasks allowed by the cgroup2 pids
+// controller. If `pids.max` is absent or set to "max", it returns
+// (-1, false, nil).
+func (cg *CGroups2) PidsMax() (int, bool, error) {
+	return NewCGroup(path.Join(cg.mountPoint, cg.groupPath)).readPidsMax()
+}
+
+// CPUSetSize returns the number of CPUs the cgroup may use, from
+// `cpuset.cpus.effective`. If the file is absent or empty, as without the
+// cpuset controller, it returns (-1, false, nil).
+func (cg *CGroups2) CPUSetSize() (int, bool, error) {
+	return NewCGroup(path.Join(cg.mountPoint, cg.groupPath)).readCPUSetSize(_cgroupv2CPUSetCPUsEffective)
+}
+
+// MemoryLimit returns the memory limit of the cgroup, in bytes, from
+// `memory.max`. If the file is absent or set to "max", it returns
+// (-1, false, nil).
+func (cg *CGroups2) MemoryLimit() (int64, bool, error) {
+	return NewCGroup(path.Join(cg.mountPoint, cg.groupPath)).readMemoryLimit(_cgroupv2MemoryMax)
 }
@@ -27,6 +27,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -152,6 +153,242 @@ func TestCGroupsCPUQuotaV2(t *testing.T) {
 	}
 }
 
+func TestCGroupsCPUPeriodV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "set",
+			want:   100000,
+			wantOK: true,
+		},
+		{
+			name:   "unset",
+			want:   100000,
+			wantOK: true,
+		},
+		{
+			name:   "only-max",
+			want:   100000,
+			wantOK: true,
+		},
+		{
+			name: "nonexistent",
+			want: -1,
+		},
+		{
+			name:    "invalid-period",
+			wantErr: `parsing "njn": invalid syntax`,
+		},
+		{
+			name:    "zero-period",
+			wantErr: "zero value for period is not allowed",
+		},
+		{
+			name:    "too-many-fields",
+			wantErr: "invalid format",
+		},
+		{
+			name:    "empty",
+			wantErr: "unexpected EOF",
+		},
+	}
+
+	mountPoint := filepath.Join(testDataCGroupsPath, "v2")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			period, defined, err := (&CGroups2{
+				mountPoint: mountPoint,
+				groupPath:  "/",
+				cpuMaxFile: tt.name,
+			}).CPUPeriod()
+
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, tt.name)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err, tt.name)
+				assert.Equal(t, tt.want, period, tt.name)
+				assert.Equal(t, tt.wantOK, defined, tt.name)
+			}
+		})
+	}
+}
+
+func TestCGroupsPathsV2(t *testing.T) {
+	cgroups := &CGroups2{mountPoint: "/sys/fs/cgroup", groupPath: "/app"}
+	assert.Equal(t, map[string]string{"cgroup2": "/sys/fs/cgroup/app"}, cgroups.Paths())
+	assert.Equal(t, 2, cgroups.Version())
+}
+
+func TestCGroupsPidsMaxV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "pids",
+			want:   1000,
+			wantOK: true,
+		},
+		{
+			name: "pids-max",
+			want: -1,
+		},
+		{
+			name: "nonexistent",
+			want: -1,
+		},
+		{
+			name:    "pids-invalid",
+			wantErr: `parsing "lots": invalid syntax`,
+		},
+		{
+			name:    "empty",
+			wantErr: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pidsMax, defined, err := (&CGroups2{
+				mountPoint: testDataCGroupsPath,
+				groupPath:  tt.name,
+			}).PidsMax()
+
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, tt.name)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err, tt.name)
+				assert.Equal(t, tt.want, pidsMax, tt.name)
+				assert.Equal(t, tt.wantOK, defined, tt.name)
+			}
+		})
+	}
+}
+
+func TestCGroupsMemoryLimitV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int64
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "memory",
+			want:   1 << 30,
+			wantOK: true,
+		},
+		{
+			name: "memory-unlimited",
+			want: -1,
+		},
+		{
+			name: "nonexistent",
+			want: -1,
+		},
+		{
+			name:    "memory-invalid",
+			wantErr: `parsing "lots": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, defined, err := (&CGroups2{
+				mountPoint: testDataCGroupsPath,
+				groupPath:  tt.name,
+			}).MemoryLimit()
+
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, tt.name)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err, tt.name)
+				assert.Equal(t, tt.want, limit, tt.name)
+				assert.Equal(t, tt.wantOK, defined, tt.name)
+			}
+		})
+	}
+}
+
+func TestCGroupsCPURequestV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    float64
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "weight",
+			want:   2073.0 / 1024,
+			wantOK: true,
+		},
+		{
+			name: "weight-min",
+			want: -1,
+		},
+		{
+			name: "nonexistent",
+			want: -1,
+		},
+		{
+			name:    "weight-invalid",
+			wantErr: `parsing "heavy": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, defined, err := (&CGroups2{
+				mountPoint: testDataCGroupsPath,
+				groupPath:  tt.name,
+			}).CPURequest()
+
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, tt.name)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err, tt.name)
+				assert.Equal(t, tt.want, request, tt.name)
+				assert.Equal(t, tt.wantOK, defined, tt.name)
+			}
+		})
+	}
+}
+
+func TestCGroupsCPURequestV2RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cgroups := &CGroups2{mountPoint: dir, groupPath: "/"}
+
+	for milliCPU := 50; milliCPU <= 64000; milliCPU += 50 {
+		shares := milliCPU * 1024 / 1000
+		weight := 1 + ((shares-2)*9999)/262142
+		require.NoError(t, os.WriteFile(filepath.Join(dir, _cgroupv2CPUWeight), []byte(strconv.Itoa(weight)), 0o644))
+
+		request, defined, err := cgroups.CPURequest()
+		require.NoError(t, err)
+		if weight <= 1 {
+			assert.False(t, defined, "%vm should be undefined", milliCPU)
+			continue
+		}
+		require.True(t, defined, "%vm should be defined", milliCPU)
+
+		want := float64(shares) / 1024
+		assert.GreaterOrEqual(t, request, want, "%vm", milliCPU)
+		assert.Less(t, request, want+0.026, "%vm", milliCPU)
+		if milliCPU%1000 == 0 {
+			assert.Equal(t, milliCPU/1000, int(request), "%vm should round down to whole CPUs", milliCPU)
+		}
+	}
+}
+
 func TestCGroup2GroupPathDiscovery(t *testing.T) {
 	tests := []struct {
 		procCgroup string
@@ -178,6 +415,27 @@ func TestCGroup2GroupPathDiscovery(t *testing.T) {
 	}
 }
 
+func TestNewCGroups2WithRoot(t *testing.T) {
+	t.Run("v2", func(t *testing.T) {
+		root := filepath.Join(testDataPath, "root", "v2")
+
+		cgroups, err := NewCGroups2WithRoot(root)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "/sys/fs/cgroup"), cgroups.mountPoint)
+		assert.Equal(t, "/app", cgroups.groupPath)
+
+		quota, defined, err := cgroups.CPUQuota()
+		require.NoError(t, err)
+		assert.True(t, defined, "quota should be defined")
+		assert.Equal(t, 1.5, quota)
+	})
+
+	t.Run("v1", func(t *testing.T) {
+		_, err := NewCGroups2WithRoot(filepath.Join(testDataPath, "root", "v1"))
+		assert.ErrorIs(t, err, ErrNotV2)
+	})
+}
+
 func TestCGroup2GroupPathDiscovery_Errors(t *testing.T) {
 	t.Run("no matching subsystem", func(t *testing.T) {
 		mountInfoPath := filepath.Join(testDataProcPath, "v2", "mountinfo-v2")
@@ -212,3 +470,48 @@ func TestCGroupsCPUQuotaV2_OtherErrors(t *testing.T) {
 		assert.Contains(t, err.Error(), "permission denied")
 	})
 }
+
+func TestCGroupsCPUSetSizeV2(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    int
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "cpuset",
+			want:   5,
+			wantOK: true,
+		},
+		{
+			name: "cpuset-empty",
+			want: -1,
+		},
+		{
+			name: "nonexistent",
+			want: -1,
+		},
+		{
+			name:    "cpuset-invalid",
+			wantErr: `parsing "x": invalid syntax`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, defined, err := (&CGroups2{
+				mountPoint: testDataCGroupsPath,
+				groupPath:  tt.name,
+			}).CPUSetSize()
+
+			if len(tt.wantErr) > 0 {
+				require.Error(t, err, tt.name)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err, tt.name)
+				assert.Equal(t, tt.want, size, tt.name)
+				assert.Equal(t, tt.wantOK, defined, tt.name)
+			}
+		})
+	}
+}
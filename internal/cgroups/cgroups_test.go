@@ -28,6 +28,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewCGroups(t *testing.T) {
@@ -55,6 +56,22 @@ func TestNewCGroups(t *testing.T) {
 	}
 }
 
+func TestNewCGroupsWithRoot(t *testing.T) {
+	root := filepath.Join(testDataPath, "root", "v1")
+
+	cgroups, err := NewCGroupsWithRoot(root)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "/sys/fs/cgroup/cpu,cpuacct"), cgroups[_cgroupSubsysCPU].Path())
+
+	quota, defined, err := cgroups.CPUQuota()
+	require.NoError(t, err)
+	assert.True(t, defined, "quota should be defined")
+	assert.Equal(t, 3.0, quota)
+
+	_, err = NewCGroupsWithRoot(filepath.Join(testDataPath, "root", "nonexistent"))
+	assert.Error(t, err)
+}
+
 func TestNewCGroupsWithErrors(t *testing.T) {
 	testTable := []struct {
 		mountInfoPath string
@@ -138,3 +155,282 @@ func TestCGroupsCPUQuota(t *testing.T) {
 		}
 	}
 }
+
+func TestCGroupsPidsMax(t *testing.T) {
+	testTable := []struct {
+		name            string
+		expectedMax     int
+		expectedDefined bool
+		shouldHaveError bool
+	}{
+		{
+			name:            "pids",
+			expectedMax:     1000,
+			expectedDefined: true,
+		},
+		{
+			name:        "pids-max",
+			expectedMax: -1,
+		},
+		{
+			name:        "cpu",
+			expectedMax: -1,
+		},
+		{
+			name:            "pids-invalid",
+			expectedMax:     -1,
+			shouldHaveError: true,
+		},
+	}
+
+	cgroups := make(CGroups)
+
+	pidsMax, defined, err := cgroups.PidsMax()
+	assert.Equal(t, -1, pidsMax, "nonexistent")
+	assert.False(t, defined, "nonexistent")
+	assert.NoError(t, err, "nonexistent")
+
+	for _, tt := range testTable {
+		cgroups[_cgroupSubsysPids] = NewCGroup(filepath.Join(testDataCGroupsPath, tt.name))
+
+		pidsMax, defined, err := cgroups.PidsMax()
+		assert.Equal(t, tt.expectedMax, pidsMax, tt.name)
+		assert.Equal(t, tt.expectedDefined, defined, tt.name)
+
+		if tt.shouldHaveError {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
+
+func TestCGroupsCPUPeriod(t *testing.T) {
+	testTable := []struct {
+		name            string
+		expectedPeriod  int
+		expectedDefined bool
+	}{
+		{
+			name:            "cpu",
+			expectedPeriod:  100000,
+			expectedDefined: true,
+		},
+		{
+			name:           "zero-period",
+			expectedPeriod: -1,
+		},
+		{
+			name:           "undefined-period",
+			expectedPeriod: -1,
+		},
+	}
+
+	cgroups := make(CGroups)
+
+	period, defined, err := cgroups.CPUPeriod()
+	assert.Equal(t, -1, period, "nonexistent")
+	assert.False(t, defined, "nonexistent")
+	assert.NoError(t, err, "nonexistent")
+
+	for _, tt := range testTable {
+		cgroups[_cgroupSubsysCPU] = NewCGroup(filepath.Join(testDataCGroupsPath, tt.name))
+
+		period, defined, err := cgroups.CPUPeriod()
+		assert.Equal(t, tt.expectedPeriod, period, tt.name)
+		assert.Equal(t, tt.expectedDefined, defined, tt.name)
+		assert.NoError(t, err, tt.name)
+	}
+
+	cgroups[_cgroupSubsysCPU] = NewCGroup(filepath.Join(testDataCGroupsPath, "period-invalid"))
+	_, _, err = cgroups.CPUPeriod()
+	assert.Error(t, err, "period-invalid")
+}
+
+func TestCGroupsPaths(t *testing.T) {
+	cgroups := CGroups{
+		_cgroupSubsysCPU:    NewCGroup("/sys/fs/cgroup/cpu,cpuacct"),
+		_cgroupSubsysMemory: NewCGroup("/sys/fs/cgroup/memory"),
+	}
+	assert.Equal(t, map[string]string{
+		"cpu":    "/sys/fs/cgroup/cpu,cpuacct",
+		"memory": "/sys/fs/cgroup/memory",
+	}, cgroups.Paths())
+	assert.Equal(t, 1, cgroups.Version())
+}
+
+func TestCGroupsMemoryLimit(t *testing.T) {
+	testTable := []struct {
+		name            string
+		expectedLimit   int64
+		expectedDefined bool
+		shouldHaveError bool
+	}{
+		{
+			name:            "memory",
+			expectedLimit:   2 << 30,
+			expectedDefined: true,
+		},
+		{
+			name:          "memory-unlimited",
+			expectedLimit: -1,
+		},
+		{
+			name:          "cpu",
+			expectedLimit: -1,
+		},
+		{
+			name:            "memory-invalid",
+			expectedLimit:   -1,
+			shouldHaveError: true,
+		},
+	}
+
+	cgroups := make(CGroups)
+
+	limit, defined, err := cgroups.MemoryLimit()
+	assert.Equal(t, int64(-1), limit, "nonexistent")
+	assert.False(t, defined, "nonexistent")
+	assert.NoError(t, err, "nonexistent")
+
+	for _, tt := range testTable {
+		cgroups[_cgroupSubsysMemory] = NewCGroup(filepath.Join(testDataCGroupsPath, tt.name))
+
+		limit, defined, err := cgroups.MemoryLimit()
+		assert.Equal(t, tt.expectedLimit, limit, tt.name)
+		assert.Equal(t, tt.expectedDefined, defined, tt.name)
+
+		if tt.shouldHaveError {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
+
+func TestCGroupsCPURequest(t *testing.T) {
+	testTable := []struct {
+		name            string
+		expectedRequest float64
+		expectedDefined bool
+		shouldHaveError bool
+	}{
+		{
+			name:            "shares",
+			expectedRequest: 2.0,
+			expectedDefined: true,
+		},
+		{
+			name:            "shares-min",
+			expectedRequest: -1,
+		},
+		{
+			name:            "cpu",
+			expectedRequest: -1,
+		},
+		{
+			name:            "shares-invalid",
+			expectedRequest: -1,
+			shouldHaveError: true,
+		},
+	}
+
+	cgroups := make(CGroups)
+
+	request, defined, err := cgroups.CPURequest()
+	assert.Equal(t, -1.0, request, "nonexistent")
+	assert.False(t, defined, "nonexistent")
+	assert.NoError(t, err, "nonexistent")
+
+	for _, tt := range testTable {
+		cgroups[_cgroupSubsysCPU] = NewCGroup(filepath.Join(testDataCGroupsPath, tt.name))
+
+		request, defined, err := cgroups.CPURequest()
+		assert.Equal(t, tt.expectedRequest, request, tt.name)
+		assert.Equal(t, tt.expectedDefined, defined, tt.name)
+
+		if tt.shouldHaveError {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
+
+func TestCGroupsCPUSetSize(t *testing.T) {
+	testTable := []struct {
+		name            string
+		expectedSize    int
+		expectedDefined bool
+		shouldHaveError bool
+	}{
+		{
+			name:            "cpuset",
+			expectedSize:    5,
+			expectedDefined: true,
+		},
+		{
+			name:         "cpuset-empty",
+			expectedSize: -1,
+		},
+		{
+			name:         "cpu",
+			expectedSize: -1,
+		},
+		{
+			name:            "cpuset-invalid",
+			expectedSize:    -1,
+			shouldHaveError: true,
+		},
+	}
+
+	cgroups := make(CGroups)
+
+	size, defined, err := cgroups.CPUSetSize()
+	assert.Equal(t, -1, size, "nonexistent")
+	assert.False(t, defined, "nonexistent")
+	assert.NoError(t, err, "nonexistent")
+
+	for _, tt := range testTable {
+		cgroups[_cgroupSubsysCPUSet] = NewCGroup(filepath.Join(testDataCGroupsPath, tt.name))
+
+		size, defined, err := cgroups.CPUSetSize()
+		assert.Equal(t, tt.expectedSize, size, tt.name)
+		assert.Equal(t, tt.expectedDefined, defined, tt.name)
+
+		if tt.shouldHaveError {
+			assert.ErrorIs(t, err, ErrInvalidFormat, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		give    string
+		want    int
+		wantErr bool
+	}{
+		{give: "0", want: 1},
+		{give: "0-7", want: 8},
+		{give: "0-3,8-11", want: 8},
+		{give: "1,3,5", want: 3},
+		{give: "2-2", want: 1},
+		{give: "3-1", wantErr: true},
+		{give: "-1", wantErr: true},
+		{give: "0-", wantErr: true},
+		{give: "a", wantErr: true},
+		{give: "0,,1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCPUList(tt.give)
+		if tt.wantErr {
+			assert.Error(t, err, tt.give)
+			continue
+		}
+		require.NoError(t, err, tt.give)
+		assert.Equal(t, tt.want, got, tt.give)
+	}
+}
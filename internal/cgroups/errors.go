@@ -18,35 +18,90 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
 // THE SOFTWARE.
 
-//go:build linux
-// +build linux
-
 package cgroups
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidFormat indicates that a line of `/proc/$PID/mountinfo`,
+	// `/proc/$PID/cgroup` or a cgroup param file is malformed.
+	ErrInvalidFormat = errors.New("invalid format")
+
+	// ErrInvalidValue indicates that a cgroup param file is well-formed but
+	// holds a value that isn't allowed, such as a zero CPU period.
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// ParseError is returned if a line of a `/proc` or cgroup file can't be
+// parsed. It wraps ErrInvalidFormat or ErrInvalidValue, along with the
+// underlying error if any, such as a *strconv.NumError.
+type ParseError struct {
+	// Path is the file that failed to parse, or empty if the line wasn't
+	// read from a file.
+	Path string
+	// Line is the 1-based line number within the file, or 0 if unknown.
+	Line int
+	// Content is the offending line.
+	Content string
+	// Err describes what's wrong with the line.
+	Err error
+}
 
-type cgroupSubsysFormatInvalidError struct {
-	line string
+func (err *ParseError) Error() string {
+	msg := fmt.Sprintf("%v: %q", err.Err, err.Content)
+	switch {
+	case err.Path == "":
+		return msg
+	case err.Line == 0:
+		return fmt.Sprintf("%v: %v", err.Path, msg)
+	default:
+		return fmt.Sprintf("%v:%v: %v", err.Path, err.Line, msg)
+	}
 }
 
-type mountPointFormatInvalidError struct {
-	line string
+// Unwrap returns the underlying error.
+func (err *ParseError) Unwrap() error {
+	return err.Err
 }
 
-type pathNotExposedFromMountPointError struct {
-	mountPoint string
-	root       string
-	path       string
+// PathNotExposedError is returned if a cgroup isn't visible from the mount
+// point of its hierarchy, for example because the mount namespace only
+// exposes a descendant cgroup.
+type PathNotExposedError struct {
+	MountPoint string
+	Root       string
+	Path       string
 }
 
-func (err cgroupSubsysFormatInvalidError) Error() string {
-	return fmt.Sprintf("invalid format for CGroupSubsys: %q", err.line)
+func (err *PathNotExposedError) Error() string {
+	return fmt.Sprintf("path %q is not a descendant of mount point root %q and cannot be exposed from %q", err.Path, err.Root, err.MountPoint)
 }
 
-func (err mountPointFormatInvalidError) Error() string {
-	return fmt.Sprintf("invalid format for MountPoint: %q", err.line)
+// invalidFormat returns an error wrapping ErrInvalidFormat for a malformed
+// what, and cause if it's not nil.
+func invalidFormat(what string, cause error) error {
+	if cause == nil {
+		return fmt.Errorf("%w for %v", ErrInvalidFormat, what)
+	}
+	return fmt.Errorf("%w for %v: %w", ErrInvalidFormat, what, cause)
 }
 
-func (err pathNotExposedFromMountPointError) Error() string {
-	return fmt.Sprintf("path %q is not a descendant of mount point root %q and cannot be exposed from %q", err.path, err.root, err.mountPoint)
+// lineError records path and line on err if it's a *ParseError. It returns
+// nil if skip is set, after passing it the error, so that the line is skipped.
+func lineError(err error, path string, line int, skip func(*ParseError)) error {
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+	if parseErr.Path == "" {
+		parseErr.Path, parseErr.Line = path, line
+	}
+	if skip != nil {
+		skip(parseErr)
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,221 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	v2 := func(name string) func() error {
+		return func() error {
+			_, _, err := (&CGroups2{
+				mountPoint: filepath.Join(testDataCGroupsPath, "v2"),
+				groupPath:  "/",
+				cpuMaxFile: name,
+			}).CPUQuota()
+			return err
+		}
+	}
+
+	tests := []struct {
+		name        string
+		give        func() error
+		wantIs      error
+		wantPath    string
+		wantLine    int
+		wantContent string
+		wantMsg     string
+	}{
+		{
+			name: "cgroup",
+			give: func() error {
+				_, err := NewCGroups("/dev/null", filepath.Join(testDataProcPath, "invalid-cgroup", "cgroup"))
+				return err
+			},
+			wantIs:      ErrInvalidFormat,
+			wantPath:    filepath.Join(testDataProcPath, "invalid-cgroup", "cgroup"),
+			wantLine:    2,
+			wantContent: "invalid-line:",
+			wantMsg:     "testdata/proc/invalid-cgroup/cgroup:2: invalid format for CGroupSubsys",
+		},
+		{
+			name: "mountinfo",
+			give: func() error {
+				_, err := NewCGroups(filepath.Join(testDataProcPath, "invalid-mountinfo", "mountinfo"), "/dev/null")
+				return err
+			},
+			wantIs:      ErrInvalidFormat,
+			wantPath:    filepath.Join(testDataProcPath, "invalid-mountinfo", "mountinfo"),
+			wantLine:    1,
+			wantContent: "1 0 8:1 / / rw,noatime shared:1 - ext4 /dev/sda1",
+			wantMsg:     "testdata/proc/invalid-mountinfo/mountinfo:1: invalid format for MountPoint",
+		},
+		{
+			name:        "cpu.max/too many fields",
+			give:        v2("too-many-fields"),
+			wantIs:      ErrInvalidFormat,
+			wantPath:    filepath.Join(testDataCGroupsPath, "v2", "too-many-fields"),
+			wantLine:    1,
+			wantContent: "250000 100000 100",
+			wantMsg:     "testdata/cgroups/v2/too-many-fields:1: invalid format for too-many-fields",
+		},
+		{
+			name:        "cpu.max/zero period",
+			give:        v2("zero-period"),
+			wantIs:      ErrInvalidValue,
+			wantPath:    filepath.Join(testDataCGroupsPath, "v2", "zero-period"),
+			wantLine:    1,
+			wantContent: "250000 0",
+			wantMsg:     `invalid value: zero value for period is not allowed: "250000 0"`,
+		},
+		{
+			name:     "cpu.max/empty",
+			give:     v2("empty"),
+			wantIs:   io.ErrUnexpectedEOF,
+			wantPath: filepath.Join(testDataCGroupsPath, "v2", "empty"),
+			wantLine: 1,
+			wantMsg:  "unexpected EOF",
+		},
+		{
+			name:     "cpu.max/empty format",
+			give:     v2("empty"),
+			wantIs:   ErrInvalidFormat,
+			wantPath: filepath.Join(testDataCGroupsPath, "v2", "empty"),
+			wantLine: 1,
+			wantMsg:  "testdata/cgroups/v2/empty:1: invalid format for empty: unexpected EOF",
+		},
+		{
+			name: "cpu.cfs_quota_us/empty",
+			give: func() error {
+				_, err := NewCGroup(filepath.Join(testDataCGroupsPath, "empty")).readInt(_cgroupCPUCFSQuotaUsParam)
+				return err
+			},
+			wantIs:   ErrInvalidFormat,
+			wantPath: filepath.Join(testDataCGroupsPath, "empty", _cgroupCPUCFSQuotaUsParam),
+			wantLine: 1,
+			wantMsg:  "invalid format for cpu.cfs_quota_us: unexpected EOF",
+		},
+		{
+			name: "cpu.cfs_quota_us",
+			give: func() error {
+				_, err := NewCGroup(filepath.Join(testDataCGroupsPath, "invalid")).readInt(_cgroupCPUCFSQuotaUsParam)
+				return err
+			},
+			wantIs:      ErrInvalidFormat,
+			wantPath:    filepath.Join(testDataCGroupsPath, "invalid", _cgroupCPUCFSQuotaUsParam),
+			wantLine:    1,
+			wantContent: "non-an-integer",
+			wantMsg:     "invalid format for cpu.cfs_quota_us: strconv.Atoi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.give()
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.wantIs)
+			assert.Contains(t, err.Error(), tt.wantMsg)
+
+			var parseErr *ParseError
+			require.True(t, errors.As(err, &parseErr), "expected a *ParseError, got %T", err)
+			assert.Equal(t, tt.wantPath, parseErr.Path)
+			assert.Equal(t, tt.wantLine, parseErr.Line)
+			assert.Equal(t, tt.wantContent, parseErr.Content)
+		})
+	}
+}
+
+func TestParseErrorUnwrapsCause(t *testing.T) {
+	_, err := NewCGroupSubsysFromLine("not-a-number:cpu:/")
+	var numErr *strconv.NumError
+	require.True(t, errors.As(err, &numErr), "expected a *strconv.NumError, got %v", err)
+	assert.Equal(t, "not-a-number", numErr.Num)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestPathNotExposedError(t *testing.T) {
+	_, err := NewCGroups(
+		filepath.Join(testDataProcPath, "untranslatable", "mountinfo"),
+		filepath.Join(testDataProcPath, "untranslatable", "cgroup"),
+	)
+	var notExposed *PathNotExposedError
+	require.True(t, errors.As(err, &notExposed), "expected a *PathNotExposedError, got %v", err)
+	assert.Equal(t, "/sys/fs/cgroup/cpuacct", notExposed.MountPoint)
+	assert.Equal(t, "/docker/0123456789abcdef", notExposed.Root)
+	assert.Equal(t, "/docker", notExposed.Path)
+}
+
+func TestSkipInvalidLines(t *testing.T) {
+	malformedPath := filepath.Join(testDataProcPath, "malformed")
+
+	t.Run("v1", func(t *testing.T) {
+		var skipped []*ParseError
+		cgroups, err := NewCGroups(
+			filepath.Join(malformedPath, "mountinfo"),
+			filepath.Join(malformedPath, "cgroup"),
+			SkipInvalidLines(func(err *ParseError) { skipped = append(skipped, err) }),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "/sys/fs/cgroup/cpu,cpuacct", cgroups[_cgroupSubsysCPU].Path())
+
+		require.Len(t, skipped, 2)
+		assert.Equal(t, filepath.Join(malformedPath, "cgroup"), skipped[0].Path)
+		assert.Equal(t, 2, skipped[0].Line)
+		assert.Equal(t, "bogus", skipped[0].Content)
+		assert.Equal(t, filepath.Join(malformedPath, "mountinfo"), skipped[1].Path)
+		assert.Equal(t, 5, skipped[1].Line)
+		assert.ErrorIs(t, skipped[1], ErrInvalidFormat)
+	})
+
+	t.Run("v1 strict", func(t *testing.T) {
+		_, err := NewCGroups(filepath.Join(malformedPath, "mountinfo"), filepath.Join(testDataProcPath, "cgroups", "cgroup"))
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		var skipped []*ParseError
+		cgroups, err := newCGroups2From(
+			filepath.Join(malformedPath, "mountinfo-v2"),
+			filepath.Join(testDataProcPath, "v2", "cgroup-root"),
+			SkipInvalidLines(func(err *ParseError) { skipped = append(skipped, err) }),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, "/", cgroups.groupPath)
+		require.Len(t, skipped, 1)
+		assert.Equal(t, 2, skipped[0].Line)
+	})
+
+	t.Run("v2 strict", func(t *testing.T) {
+		_, err := newCGroups2From(filepath.Join(malformedPath, "mountinfo-v2"), filepath.Join(testDataProcPath, "v2", "cgroup-root"))
+		assert.ErrorIs(t, err, ErrInvalidFormat)
+	})
+}
@@ -80,17 +80,17 @@ func NewMountPointFromLine(line string) (*MountPoint, error) {
 	fields := strings.Split(line, _mountInfoSep)
 
 	if len(fields) < _miFieldCountMin {
-		return nil, mountPointFormatInvalidError{line}
+		return nil, &ParseError{Content: line, Err: invalidFormat("MountPoint", nil)}
 	}
 
 	mountID, err := strconv.Atoi(fields[_miFieldIDMountID])
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Content: line, Err: invalidFormat("MountPoint", err)}
 	}
 
 	parentID, err := strconv.Atoi(fields[_miFieldIDParentID])
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Content: line, Err: invalidFormat("MountPoint", err)}
 	}
 
 	for i, field := range fields[_miFieldIDOptionalFields:] {
@@ -102,7 +102,7 @@ func NewMountPointFromLine(line string) (*MountPoint, error) {
 			// limit to avoid issues with spaces in super options as present on WSL.
 			fields = strings.SplitN(line, _mountInfoSep, fsTypeStart+_miFieldCountSecondHalf)
 			if len(fields) != fsTypeStart+_miFieldCountSecondHalf {
-				return nil, mountPointFormatInvalidError{line}
+				return nil, &ParseError{Content: line, Err: invalidFormat("MountPoint", nil)}
 			}
 
 			miFieldIDFSType := _miFieldOffsetFSType + fsTypeStart
@@ -124,7 +124,7 @@ func NewMountPointFromLine(line string) (*MountPoint, error) {
 		}
 	}
 
-	return nil, mountPointFormatInvalidError{line}
+	return nil, &ParseError{Content: line, Err: invalidFormat("MountPoint", nil)}
 }
 
 // Translate converts an absolute path inside the *MountPoint's file system to
@@ -136,10 +136,10 @@ func (mp *MountPoint) Translate(absPath string) (string, error) {
 		return "", err
 	}
 	if relPath == ".." || strings.HasPrefix(relPath, "../") {
-		return "", pathNotExposedFromMountPointError{
-			mountPoint: mp.MountPoint,
-			root:       mp.Root,
-			path:       absPath,
+		return "", &PathNotExposedError{
+			MountPoint: mp.MountPoint,
+			Root:       mp.Root,
+			Path:       absPath,
 		}
 	}
 
@@ -147,8 +147,9 @@ func (mp *MountPoint) Translate(absPath string) (string, error) {
 }
 
 // parseMountInfo parses procPathMountInfo (usually at `/proc/$PID/mountinfo`)
-// and yields parsed *MountPoint into newMountPoint.
-func parseMountInfo(procPathMountInfo string, newMountPoint func(*MountPoint) error) error {
+// and yields parsed *MountPoint into newMountPoint. Lines that can't be parsed
+// are passed to skip and skipped if it's set.
+func parseMountInfo(procPathMountInfo string, newMountPoint func(*MountPoint) error, skip func(*ParseError)) error {
 	mountInfoFile, err := os.Open(procPathMountInfo)
 	if err != nil {
 		return err
@@ -157,10 +158,13 @@ func parseMountInfo(procPathMountInfo string, newMountPoint func(*MountPoint) er
 
 	scanner := bufio.NewScanner(mountInfoFile)
 
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		mountPoint, err := NewMountPointFromLine(scanner.Text())
 		if err != nil {
-			return err
+			if err := lineError(err, procPathMountInfo, lineNum, skip); err != nil {
+				return err
+			}
+			continue
 		}
 		if err := newMountPoint(mountPoint); err != nil {
 			return err
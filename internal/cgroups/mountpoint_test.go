@@ -24,6 +24,7 @@
 package cgroups
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -124,10 +125,10 @@ func TestNewMountPointFromLineErr(t *testing.T) {
 
 	for i, line := range linesWithInvalidFields {
 		mountPoint, err := NewMountPointFromLine(line)
-		errExpected := mountPointFormatInvalidError{line}
 
 		assert.Nil(t, mountPoint, "[%d] %q", i, line)
-		assert.Equal(t, err, errExpected, "[%d] %q", i, line)
+		assert.ErrorIs(t, err, ErrInvalidFormat, "[%d] %q", i, line)
+		assert.EqualError(t, err, fmt.Sprintf("invalid format for MountPoint: %q", line), "[%d] %q", i, line)
 	}
 }
 
@@ -184,10 +185,10 @@ func TestMountPointTranslateError(t *testing.T) {
 
 	for i, path := range inaccessiblePaths {
 		translated, err := cgroupMountPoint.Translate(path)
-		errExpected := pathNotExposedFromMountPointError{
-			mountPoint: cgroupMountPoint.MountPoint,
-			root:       cgroupMountPoint.Root,
-			path:       path,
+		errExpected := &PathNotExposedError{
+			MountPoint: cgroupMountPoint.MountPoint,
+			Root:       cgroupMountPoint.Root,
+			Path:       path,
 		}
 
 		assert.Equal(t, "", translated, "inaccessiblePaths[%d] == %q", i, path)
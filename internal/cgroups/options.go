@@ -0,0 +1,52 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+// Option configures how the cgroups of a process are discovered.
+type Option func(*options)
+
+type options struct {
+	// skipInvalidLine, if set, is called with each line of `mountinfo` or
+	// `cgroup` that can't be parsed, which is then skipped.
+	skipInvalidLine func(*ParseError)
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SkipInvalidLines makes discovery skip the lines of `/proc/$PID/mountinfo`
+// and `/proc/$PID/cgroup` that can't be parsed, rather than fail, passing each
+// of them to f. The cgroups are still discovered from the remaining lines.
+//
+// Malformed cgroup param files such as `cpu.max` are always errors.
+func SkipInvalidLines(f func(*ParseError)) Option {
+	return func(o *options) {
+		o.skipInvalidLine = f
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+import "path/filepath"
+
+// Snapshot holds the cgroup mount points and memberships of a process, read
+// once from `/proc/$PID/mountinfo` and `/proc/$PID/cgroup`. Both cgroups v1
+// and v2 are discovered from the same Snapshot, so falling back from one to
+// the other doesn't parse the files again.
+type Snapshot struct {
+	// root is joined to the cgroup paths, as for NewCGroupsWithRoot.
+	root string
+	// mountPoints holds the cgroup and cgroup2 mount points, in order.
+	mountPoints []*MountPoint
+	subsystems  map[string]*CGroupSubsys
+}
+
+// NewSnapshot reads the given `mountinfo` and `cgroup` files for some process
+// under the `/proc` file system.
+func NewSnapshot(procPathMountInfo, procPathCGroup string, opts ...Option) (*Snapshot, error) {
+	o := newOptions(opts)
+
+	subsystems, err := parseCGroupSubsystems(procPathCGroup, o.skipInvalidLine)
+	if err != nil {
+		return nil, err
+	}
+
+	var mountPoints []*MountPoint
+	newMountPoint := func(mp *MountPoint) error {
+		if mp.FSType == _cgroupFSType || mp.FSType == _cgroupv2FSType {
+			mountPoints = append(mountPoints, mp)
+		}
+		return nil
+	}
+	if err := parseMountInfo(procPathMountInfo, newMountPoint, o.skipInvalidLine); err != nil {
+		return nil, err
+	}
+	return &Snapshot{mountPoints: mountPoints, subsystems: subsystems}, nil
+}
+
+// NewSnapshotWithRoot reads the `/proc` files of the current process,
+// resolving them and the cgroup file system paths against root rather than
+// against `/`.
+func NewSnapshotWithRoot(root string, opts ...Option) (*Snapshot, error) {
+	s, err := NewSnapshot(
+		filepath.Join(root, _procPathMountInfo),
+		filepath.Join(root, _procPathCGroup),
+		opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.root = root
+	return s, nil
+}
+
+// CGroups returns the cgroups v1 of the process, by subsystem.
+func (s *Snapshot) CGroups() (CGroups, error) {
+	cgroups := make(CGroups)
+	for _, mp := range s.mountPoints {
+		if mp.FSType != _cgroupFSType {
+			continue
+		}
+
+		for _, opt := range mp.SuperOptions {
+			subsys, exists := s.subsystems[opt]
+			if !exists {
+				continue
+			}
+
+			cgroupPath, err := mp.Translate(subsys.Name)
+			if err != nil {
+				return nil, err
+			}
+			cgroups[opt] = NewCGroup(filepath.Join(s.root, cgroupPath))
+		}
+	}
+	return cgroups, nil
+}
+
+// CGroups2 returns the cgroup of the process in the cgroups v2 hierarchy.
+//
+// This returns ErrNotV2 if the system is not using cgroups2.
+func (s *Snapshot) CGroups2() (*CGroups2, error) {
+	isV2 := false
+	for _, mp := range s.mountPoints {
+		isV2 = isV2 || (mp.FSType == _cgroupv2FSType && mp.MountPoint == _cgroupv2MountPoint)
+	}
+	if !isV2 {
+		return nil, ErrNotV2
+	}
+
+	// Find v2 subsystem by looking for the `0` id
+	var v2subsys *CGroupSubsys
+	for _, subsys := range s.subsystems {
+		if subsys.ID == 0 {
+			v2subsys = subsys
+			break
+		}
+	}
+
+	if v2subsys == nil {
+		return nil, ErrNotV2
+	}
+
+	return &CGroups2{
+		mountPoint: filepath.Join(s.root, _cgroupv2MountPoint),
+		groupPath:  v2subsys.Name,
+		cpuMaxFile: _cgroupv2CPUMax,
+	}, nil
+}
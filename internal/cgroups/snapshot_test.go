@@ -0,0 +1,115 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package cgroups
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	t.Run("v1", func(t *testing.T) {
+		snapshot, err := NewSnapshot(
+			filepath.Join(testDataProcPath, "cgroups", "mountinfo"),
+			filepath.Join(testDataProcPath, "cgroups", "cgroup"),
+		)
+		require.NoError(t, err)
+
+		_, err = snapshot.CGroups2()
+		assert.ErrorIs(t, err, ErrNotV2)
+
+		cgroups, err := snapshot.CGroups()
+		require.NoError(t, err)
+		assert.Equal(t, "/sys/fs/cgroup/cpu,cpuacct", cgroups[_cgroupSubsysCPU].Path())
+		assert.Equal(t, "/sys/fs/cgroup/memory/large", cgroups[_cgroupSubsysMemory].Path())
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		root := filepath.Join(testDataPath, "root", "v2")
+		snapshot, err := NewSnapshotWithRoot(root)
+		require.NoError(t, err)
+
+		cgroups2, err := snapshot.CGroups2()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "/sys/fs/cgroup"), cgroups2.mountPoint)
+		assert.Equal(t, "/app", cgroups2.groupPath)
+
+		cgroups, err := snapshot.CGroups()
+		require.NoError(t, err)
+		assert.Empty(t, cgroups, "there are no v1 hierarchies")
+	})
+
+	t.Run("v1 with root", func(t *testing.T) {
+		root := filepath.Join(testDataPath, "root", "v1")
+		snapshot, err := NewSnapshotWithRoot(root)
+		require.NoError(t, err)
+
+		cgroups, err := snapshot.CGroups()
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(root, "/sys/fs/cgroup/cpu,cpuacct"), cgroups[_cgroupSubsysCPU].Path())
+	})
+}
+
+// BenchmarkDiscovery compares discovering the cgroups of a process with
+// cgroups v1 by trying v2 and then v1, as automaxprocs does at startup, with
+// and without sharing a Snapshot.
+func BenchmarkDiscovery(b *testing.B) {
+	root := filepath.Join(testDataPath, "root", "v1")
+
+	b.Run("separate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewCGroups2WithRoot(root); err != ErrNotV2 {
+				b.Fatalf("expected ErrNotV2, got %v", err)
+			}
+			// Both /proc files are read again.
+			snapshot, err := NewSnapshotWithRoot(root)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := snapshot.CGroups(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("snapshot", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			snapshot, err := NewSnapshotWithRoot(root)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := snapshot.CGroups2(); err != ErrNotV2 {
+				b.Fatalf("expected ErrNotV2, got %v", err)
+			}
+			if _, err := snapshot.CGroups(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
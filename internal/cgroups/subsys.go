@@ -56,12 +56,12 @@ func NewCGroupSubsysFromLine(line string) (*CGroupSubsys, error) {
 	fields := strings.SplitN(line, _cgroupSep, _csFieldCount)
 
 	if len(fields) != _csFieldCount {
-		return nil, cgroupSubsysFormatInvalidError{line}
+		return nil, &ParseError{Content: line, Err: invalidFormat("CGroupSubsys", nil)}
 	}
 
 	id, err := strconv.Atoi(fields[_csFieldIDID])
 	if err != nil {
-		return nil, err
+		return nil, &ParseError{Content: line, Err: invalidFormat("CGroupSubsys", err)}
 	}
 
 	cgroup := &CGroupSubsys{
@@ -75,7 +75,8 @@ func NewCGroupSubsysFromLine(line string) (*CGroupSubsys, error) {
 
 // parseCGroupSubsystems parses procPathCGroup (usually at `/proc/$PID/cgroup`)
 // and returns a new map[string]*CGroupSubsys.
-func parseCGroupSubsystems(procPathCGroup string) (map[string]*CGroupSubsys, error) {
+// Lines that can't be parsed are passed to skip and skipped if it's set.
+func parseCGroupSubsystems(procPathCGroup string, skip func(*ParseError)) (map[string]*CGroupSubsys, error) {
 	cgroupFile, err := os.Open(procPathCGroup)
 	if err != nil {
 		return nil, err
@@ -85,10 +86,13 @@ func parseCGroupSubsystems(procPathCGroup string) (map[string]*CGroupSubsys, err
 	scanner := bufio.NewScanner(cgroupFile)
 	subsystems := make(map[string]*CGroupSubsys)
 
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		cgroup, err := NewCGroupSubsysFromLine(scanner.Text())
 		if err != nil {
-			return nil, err
+			if err := lineError(err, procPathCGroup, lineNum, skip); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		for _, subsys := range cgroup.Subsystems {
 			subsystems[subsys] = cgroup
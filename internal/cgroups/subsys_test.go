@@ -87,12 +87,12 @@ func TestNewCGroupSubsysFromLineErr(t *testing.T) {
 		{
 			name:          "fewer-fields",
 			line:          lines[0],
-			expectedError: cgroupSubsysFormatInvalidError{lines[0]},
+			expectedError: &ParseError{Content: lines[0], Err: invalidFormat("CGroupSubsys", nil)},
 		},
 		{
 			name:          "illegal-id",
 			line:          lines[1],
-			expectedError: parseError,
+			expectedError: &ParseError{Content: lines[1], Err: invalidFormat("CGroupSubsys", parseError)},
 		},
 	}
 
@@ -100,5 +100,6 @@ func TestNewCGroupSubsysFromLineErr(t *testing.T) {
 		subsys, err := NewCGroupSubsysFromLine(tt.line)
 		assert.Nil(t, subsys, tt.name)
 		assert.Equal(t, tt.expectedError, err, tt.name)
+		assert.ErrorIs(t, err, ErrInvalidFormat, tt.name)
 	}
 }
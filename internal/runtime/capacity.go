@@ -0,0 +1,113 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// _capacityScale is the capacity of the largest CPUs of the host, to which
+// the kernel scales the others' (SCHED_CAPACITY_SCALE).
+const _capacityScale = 1024
+
+// cpuLimit describes the CPUs the calling process may run on.
+type cpuLimit struct {
+	// count is the number of CPUs in the affinity mask, or 0 if unknown.
+	count int
+	// weighted is their capacity-weighted count, or 0 if not computed.
+	weighted float64
+	// cores is the number of physical cores they belong to, or 0 if not
+	// counted.
+	cores int
+	// limit is the most GOMAXPROCS the CPUs warrant: the smallest of
+	// weighted rounded and cores, or count. It's 0 if unknown. status is the
+	// status of a GOMAXPROCS limited to it.
+	limit  int
+	status CPUQuotaStatus
+}
+
+// detectCPUs returns the CPUs the calling process may run on, weighted by
+// capacity if cfg.CapacityWeighted is set, and counting physical cores if
+// cfg.PhysicalCores is set.
+func detectCPUs(cfg Config, round func(float64) int) (cpuLimit, error) {
+	// Errors reading the affinity mask are ignored since the Go runtime
+	// already limits GOMAXPROCS to the CPUs available at startup.
+	cpus, err := _cpuAffinity()
+	if err != nil || len(cpus) == 0 {
+		return cpuLimit{}, nil
+	}
+	l := cpuLimit{count: len(cpus), limit: len(cpus), status: CPUQuotaAffinityUsed}
+	if cfg.CapacityWeighted {
+		weighted, defined, err := cpuCapacity(cpus)
+		if err != nil {
+			return l, err
+		}
+		if defined {
+			l.weighted, l.limit = weighted, round(weighted)
+			if l.limit < 1 {
+				l.limit = 1
+			}
+		}
+	}
+	if cfg.PhysicalCores {
+		cores, defined, err := physicalCores(cpus)
+		if err != nil {
+			return l, err
+		}
+		if defined {
+			l.cores = cores
+			if cores < l.limit {
+				l.limit, l.status = cores, CPUQuotaCoresUsed
+			}
+		}
+	}
+	return l, nil
+}
+
+// cpuCapacity returns the capacity of cpus in units of the largest CPUs of
+// the host, from `/sys/devices/system/cpu/cpuN/cpu_capacity`, and whether it's
+// defined. It's undefined unless the kernel reports the capacity of every
+// CPU, as on hybrid and big.LITTLE systems.
+func cpuCapacity(cpus []int) (float64, bool, error) {
+	var total int
+	for _, cpu := range cpus {
+		path := filepath.Join(_root, "/sys/devices/system/cpu", "cpu"+strconv.Itoa(cpu), "cpu_capacity")
+		text, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return -1, false, nil
+		}
+		if err != nil {
+			return -1, false, err
+		}
+		capacity, err := strconv.Atoi(strings.TrimSpace(string(text)))
+		if err != nil || capacity <= 0 {
+			return -1, false, fmt.Errorf("invalid CPU capacity %q in %v", strings.TrimSpace(string(text)), path)
+		}
+		total += capacity
+	}
+	return float64(total) / _capacityScale, true, nil
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// _containerMarkerFiles are files that container runtimes create in the root
+// file system of containers.
+var _containerMarkerFiles = []string{
+	"/.dockerenv",        // Docker
+	"/run/.containerenv", // Podman
+}
+
+// _containerMarkerEnvs are environment variables that container runtimes and
+// orchestrators set in containers.
+var _containerMarkerEnvs = []string{
+	"KUBERNETES_SERVICE_HOST", // Kubernetes
+	"container",               // systemd-nspawn, Podman, LXC
+}
+
+// ContainerEvidence returns a description of why the calling process appears
+// to run in a container, or the empty string if it doesn't.
+func ContainerEvidence() string {
+	for _, name := range _containerMarkerFiles {
+		if _, err := os.Stat(filepath.Join(_root, name)); err == nil {
+			return fmt.Sprintf("found %v", name)
+		}
+	}
+	for _, name := range _containerMarkerEnvs {
+		if v := os.Getenv(name); v != "" {
+			return fmt.Sprintf("%v=%q is set", name, v)
+		}
+	}
+	return ""
+}
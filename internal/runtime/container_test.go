@@ -0,0 +1,62 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerEvidence(t *testing.T) {
+	clearEnv := func(t *testing.T) {
+		for _, name := range _containerMarkerEnvs {
+			t.Setenv(name, "")
+		}
+	}
+
+	t.Run("none", func(t *testing.T) {
+		clearEnv(t)
+		defer SetRoot(t.TempDir())()
+
+		assert.Empty(t, ContainerEvidence())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		clearEnv(t)
+		root := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(root, ".dockerenv"), nil, 0o644))
+		defer SetRoot(root)()
+
+		assert.Equal(t, "found /.dockerenv", ContainerEvidence())
+	})
+
+	t.Run("env", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("container", "podman")
+		defer SetRoot(t.TempDir())()
+
+		assert.Equal(t, `container="podman" is set`, ContainerEvidence())
+	})
+}
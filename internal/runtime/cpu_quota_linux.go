@@ -25,51 +25,78 @@ package runtime
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 
 	cg "go.uber.org/automaxprocs/internal/cgroups"
 )
 
-// CPUQuotaToGOMAXPROCS converts the CPU quota applied to the calling process
-// to a valid GOMAXPROCS value. The quota is converted from float to int using round.
-// If round == nil, DefaultRoundFunc is used.
-func CPUQuotaToGOMAXPROCS(minValue int, round func(v float64) int) (int, CPUQuotaStatus, error) {
-	if round == nil {
-		round = DefaultRoundFunc
-	}
-	cgroups, err := _newQueryer()
+var (
+	_newSnapshot = cg.NewSnapshotWithRoot
+	_newCgroups2 = (*cg.Snapshot).CGroups2
+	_newCgroups  = (*cg.Snapshot).CGroups
+)
+
+// newQueryer returns the queryer for the cgroups of the calling process, using
+// cgroups v2 if available and v1 otherwise. The `/proc` files are read once
+// for both. If onInvalidLine is set, lines of `mountinfo` and `cgroup` that
+// can't be parsed are passed to it and skipped.
+func newQueryer(onInvalidLine func(error)) (queryer, error) {
+	snapshot, err := _newSnapshot(_root, cgroupsOptions(onInvalidLine)...)
 	if err != nil {
-		return -1, CPUQuotaUndefined, err
+		return nil, err
 	}
-
-	quota, defined, err := cgroups.CPUQuota()
-	if !defined || err != nil {
-		return -1, CPUQuotaUndefined, err
+	cgroups, err := _newCgroups2(snapshot)
+	if err == nil {
+		return cgroups, nil
 	}
-
-	maxProcs := round(quota)
-	if minValue > 0 && maxProcs < minValue {
-		return minValue, CPUQuotaMinUsed, nil
+	if errors.Is(err, cg.ErrNotV2) {
+		return _newCgroups(snapshot)
 	}
-	return maxProcs, CPUQuotaUsed, nil
+	return nil, err
 }
 
-type queryer interface {
-	CPUQuota() (float64, bool, error)
+func newCGroupsV1(onInvalidLine func(error)) (queryer, error) {
+	snapshot, err := _newSnapshot(_root, cgroupsOptions(onInvalidLine)...)
+	if err != nil {
+		return nil, err
+	}
+	return _newCgroups(snapshot)
 }
 
-var (
-	_newCgroups2 = cg.NewCGroups2ForCurrentProcess
-	_newCgroups  = cg.NewCGroupsForCurrentProcess
-	_newQueryer  = newQueryer
-)
-
-func newQueryer() (queryer, error) {
-	cgroups, err := _newCgroups2()
+func newCGroupsV2(onInvalidLine func(error)) (queryer, error) {
+	snapshot, err := _newSnapshot(_root, cgroupsOptions(onInvalidLine)...)
+	if err != nil {
+		return nil, err
+	}
+	cgroups, err := _newCgroups2(snapshot)
 	if err == nil {
 		return cgroups, nil
 	}
 	if errors.Is(err, cg.ErrNotV2) {
-		return _newCgroups()
+		return undefinedQueryer{}, nil
 	}
 	return nil, err
 }
+
+func cgroupsOptions(onInvalidLine func(error)) []cg.Option {
+	if onInvalidLine == nil {
+		return nil
+	}
+	return []cg.Option{cg.SkipInvalidLines(func(err *cg.ParseError) { onInvalidLine(err) })}
+}
+
+// hostCPUs returns the number of online CPUs, from
+// `/sys/devices/system/cpu/online`, or runtime.NumCPU() if that can't be read.
+func hostCPUs() int {
+	text, err := os.ReadFile(filepath.Join(_root, "/sys/devices/system/cpu/online"))
+	if err != nil {
+		return _numCPU()
+	}
+	n, err := cg.ParseCPUList(strings.TrimSpace(string(text)))
+	if err != nil {
+		return _numCPU()
+	}
+	return n
+}
@@ -27,6 +27,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/prashantv/gostub"
@@ -42,7 +45,7 @@ func TestNewQueryer(t *testing.T) {
 		c2 := new(cgroups.CGroups2)
 		stubs.StubFunc(&_newCgroups2, c2, nil)
 
-		got, err := newQueryer()
+		got, err := newQueryer(nil)
 		require.NoError(t, err)
 		assert.Same(t, c2, got)
 	})
@@ -53,7 +56,7 @@ func TestNewQueryer(t *testing.T) {
 		giveErr := errors.New("great sadness")
 		stubs.StubFunc(&_newCgroups2, nil, giveErr)
 
-		_, err := newQueryer()
+		_, err := newQueryer(nil)
 		assert.ErrorIs(t, err, giveErr)
 	})
 
@@ -66,7 +69,7 @@ func TestNewQueryer(t *testing.T) {
 		c1 := make(cgroups.CGroups)
 		stubs.StubFunc(&_newCgroups, c1, nil)
 
-		got, err := newQueryer()
+		got, err := newQueryer(nil)
 		require.NoError(t, err)
 		assert.IsType(t, c1, got, "must be a v1 cgroup")
 	})
@@ -79,7 +82,7 @@ func TestNewQueryer(t *testing.T) {
 		giveErr := errors.New("great sadness")
 		stubs.StubFunc(&_newCgroups, nil, giveErr)
 
-		_, err := newQueryer()
+		_, err := newQueryer(nil)
 		assert.ErrorIs(t, err, giveErr)
 	})
 
@@ -90,7 +93,7 @@ func TestNewQueryer(t *testing.T) {
 		stubs.StubFunc(&_newQueryer, q, nil)
 
 		// If round function is nil, CPUQuotaToGOMAXPROCS uses DefaultRoundFunc, which rounds down the value
-		got, _, err := CPUQuotaToGOMAXPROCS(0, nil)
+		got, _, err := CPUQuotaToGOMAXPROCS(Config{})
 		require.NoError(t, err)
 		assert.Equal(t, 2, got)
 	})
@@ -101,7 +104,7 @@ func TestNewQueryer(t *testing.T) {
 		q := testQueryer{v: 2.7}
 		stubs.StubFunc(&_newQueryer, q, nil)
 
-		got, _, err := CPUQuotaToGOMAXPROCS(0, func(v float64) int { return int(math.Ceil(v)) })
+		got, _, err := CPUQuotaToGOMAXPROCS(Config{Round: func(v float64) int { return int(math.Ceil(v)) }})
 		require.NoError(t, err)
 		assert.Equal(t, 3, got)
 	})
@@ -112,22 +115,936 @@ func TestNewQueryer(t *testing.T) {
 		q := testQueryer{v: 2.7}
 		stubs.StubFunc(&_newQueryer, q, nil)
 
-		got, _, err := CPUQuotaToGOMAXPROCS(0, func(v float64) int { return int(math.Floor(v)) })
+		got, _, err := CPUQuotaToGOMAXPROCS(Config{Round: func(v float64) int { return int(math.Floor(v)) }})
 		require.NoError(t, err)
 		assert.Equal(t, 2, got)
 	})
 }
 
+func TestNewQueryerSnapshot(t *testing.T) {
+	t.Run("read once", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.Stub(&_root, filepath.Join("..", "cgroups", "testdata", "root", "v1"))
+
+		var reads int
+		stubs.Stub(&_newSnapshot, func(root string, opts ...cgroups.Option) (*cgroups.Snapshot, error) {
+			reads++
+			return cgroups.NewSnapshotWithRoot(root, opts...)
+		})
+
+		got, err := newQueryer(nil)
+		require.NoError(t, err)
+		assert.IsType(t, make(cgroups.CGroups), got, "must be a v1 cgroup")
+		assert.Equal(t, 1, reads, "should read the /proc files once for v2 and v1")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		stubs := newStubs(t)
+
+		giveErr := errors.New("great sadness")
+		stubs.StubFunc(&_newSnapshot, nil, giveErr)
+
+		_, err := newQueryer(nil)
+		assert.ErrorIs(t, err, giveErr)
+		_, err = newCGroupsV1(nil)
+		assert.ErrorIs(t, err, giveErr)
+		_, err = newCGroupsV2(nil)
+		assert.ErrorIs(t, err, giveErr)
+	})
+}
+
+func TestCPURequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		queryer    testQueryer
+		multiplier float64
+		min        int
+		want       int
+		wantStatus CPUQuotaStatus
+	}{
+		{
+			name:       "quota preferred",
+			queryer:    testQueryer{v: 2.5, request: 4},
+			multiplier: 1,
+			want:       2,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "disabled",
+			queryer:    testQueryer{request: 4},
+			want:       -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+		{
+			name:       "request undefined",
+			queryer:    testQueryer{},
+			multiplier: 1,
+			want:       -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+		{
+			name:       "request used",
+			queryer:    testQueryer{request: 1.5},
+			multiplier: 2,
+			want:       3,
+			wantStatus: CPUQuotaRequestUsed,
+		},
+		{
+			name:       "bounded by NumCPU",
+			queryer:    testQueryer{request: 6},
+			multiplier: 2,
+			want:       8,
+			wantStatus: CPUQuotaRequestUsed,
+		},
+		{
+			name:       "min used",
+			queryer:    testQueryer{request: 0.25},
+			multiplier: 1,
+			min:        1,
+			want:       1,
+			wantStatus: CPUQuotaMinUsed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+			stubs.StubFunc(&_numCPU, 8)
+
+			got, status, err := CPUQuotaToGOMAXPROCS(Config{
+				Min:               tt.min,
+				RequestMultiplier: tt.multiplier,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantStatus, status)
+		})
+	}
+}
+
+func TestReserve(t *testing.T) {
+	tests := []struct {
+		name         string
+		queryer      testQueryer
+		multiplier   float64
+		cpus         float64
+		fraction     float64
+		min          int
+		want         int
+		wantStatus   CPUQuotaStatus
+		wantReserved float64
+	}{
+		{
+			name:       "none",
+			queryer:    testQueryer{v: 4},
+			want:       4,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:         "cpus",
+			queryer:      testQueryer{v: 4},
+			cpus:         0.5,
+			want:         3,
+			wantStatus:   CPUQuotaUsed,
+			wantReserved: 0.5,
+		},
+		{
+			name:         "fraction",
+			queryer:      testQueryer{v: 4},
+			fraction:     0.25,
+			want:         3,
+			wantStatus:   CPUQuotaUsed,
+			wantReserved: 1,
+		},
+		{
+			name:         "cpus and fraction",
+			queryer:      testQueryer{v: 8},
+			cpus:         1,
+			fraction:     0.25,
+			want:         5,
+			wantStatus:   CPUQuotaUsed,
+			wantReserved: 3,
+		},
+		{
+			name:         "request",
+			queryer:      testQueryer{request: 2},
+			multiplier:   2,
+			cpus:         1,
+			want:         3,
+			wantStatus:   CPUQuotaRequestUsed,
+			wantReserved: 1,
+		},
+		{
+			name:         "never below min",
+			queryer:      testQueryer{v: 2},
+			cpus:         1.5,
+			min:          1,
+			want:         1,
+			wantStatus:   CPUQuotaMinUsed,
+			wantReserved: 1.5,
+		},
+		{
+			name:         "never more than quota",
+			queryer:      testQueryer{v: 2},
+			cpus:         3,
+			min:          2,
+			want:         2,
+			wantStatus:   CPUQuotaMinUsed,
+			wantReserved: 2,
+		},
+		{
+			name:       "negative ignored",
+			queryer:    testQueryer{v: 2},
+			cpus:       -1,
+			fraction:   -0.5,
+			want:       2,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "quota undefined",
+			queryer:    testQueryer{},
+			cpus:       1,
+			want:       -1,
+			wantStatus: CPUQuotaUndefined,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+			stubs.StubFunc(&_numCPU, 8)
+
+			got, err := DetectGOMAXPROCS(Config{
+				Min:               tt.min,
+				RequestMultiplier: tt.multiplier,
+				ReserveCPUs:       tt.cpus,
+				ReserveFraction:   tt.fraction,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.GOMAXPROCS)
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantReserved, got.Reserved)
+		})
+	}
+}
+
+func TestQuotaSources(t *testing.T) {
+	t.Run("custom source", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, nil, errors.New("cgroups shouldn't be read"))
+
+		got, status, err := CPUQuotaToGOMAXPROCS(Config{Source: testQueryer{v: 3.5}})
+		require.NoError(t, err)
+		assert.Equal(t, 3, got)
+		assert.Equal(t, CPUQuotaUsed, status)
+	})
+
+	t.Run("custom source with request", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{request: 2}, nil)
+		stubs.StubFunc(&_numCPU, 8)
+
+		got, status, err := CPUQuotaToGOMAXPROCS(Config{
+			Source:            testQueryer{},
+			RequestMultiplier: 1,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 2, got)
+		assert.Equal(t, CPUQuotaRequestUsed, status)
+	})
+
+	t.Run("custom source error", func(t *testing.T) {
+		newStubs(t)
+
+		giveErr := errors.New("great sadness")
+		_, status, err := CPUQuotaToGOMAXPROCS(Config{Source: queryerSource(func() (queryer, error) {
+			return nil, giveErr
+		})})
+		assert.ErrorIs(t, err, giveErr)
+		assert.Equal(t, CPUQuotaUndefined, status)
+	})
+
+	t.Run("v1", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newCgroups2, nil, errors.New("v2 shouldn't be read"))
+		stubs.StubFunc(&_newCgroups, make(cgroups.CGroups), nil)
+
+		_, defined, err := CGroupsV1Source().CPUQuota()
+		require.NoError(t, err)
+		assert.False(t, defined, "quota should be undefined")
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		root := filepath.Join("..", "cgroups", "testdata", "root", "v2")
+		stubs := newStubs(t)
+		stubs.Stub(&_root, root)
+		stubs.Stub(&_newSnapshot, cgroups.NewSnapshotWithRoot)
+		stubs.StubFunc(&_newCgroups, nil, errors.New("v1 shouldn't be read"))
+
+		quota, defined, err := CGroupsV2Source().CPUQuota()
+		require.NoError(t, err)
+		assert.True(t, defined, "quota should be defined")
+		assert.Equal(t, 1.5, quota)
+	})
+
+	t.Run("v2 not used", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newCgroups2, nil, cgroups.ErrNotV2)
+
+		_, defined, err := CGroupsV2Source().CPUQuota()
+		require.NoError(t, err)
+		assert.False(t, defined, "quota should be undefined")
+	})
+
+	t.Run("v2 error", func(t *testing.T) {
+		stubs := newStubs(t)
+		giveErr := errors.New("great sadness")
+		stubs.StubFunc(&_newCgroups2, nil, giveErr)
+
+		_, _, err := CGroupsV2Source().CPUQuota()
+		assert.ErrorIs(t, err, giveErr)
+	})
+}
+
+func TestEffectiveCPUs(t *testing.T) {
+	tests := []struct {
+		name     string
+		numCPU   int
+		affinity []int
+		queryer  testQueryer
+		want     float64
+	}{
+		{
+			name:    "no limits",
+			numCPU:  16,
+			queryer: testQueryer{},
+			want:    16,
+		},
+		{
+			name:     "affinity",
+			numCPU:   16,
+			affinity: []int{0, 1, 2, 3, 4, 5},
+			queryer:  testQueryer{},
+			want:     6,
+		},
+		{
+			name:     "cpuset",
+			numCPU:   16,
+			affinity: []int{0, 1, 2, 3, 4, 5},
+			queryer:  testQueryer{cpuset: 4},
+			want:     4,
+		},
+		{
+			name:     "quota",
+			numCPU:   16,
+			affinity: []int{0, 1, 2, 3, 4, 5},
+			queryer:  testQueryer{v: 2.5, cpuset: 4},
+			want:     2.5,
+		},
+		{
+			name:    "quota above cpuset",
+			numCPU:  16,
+			queryer: testQueryer{v: 12, cpuset: 8},
+			want:    8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.StubFunc(&_numCPU, tt.numCPU)
+			stubs.StubFunc(&_cpuAffinity, tt.affinity, nil)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+
+			got, err := EffectiveCPUs()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_numCPU, 16)
+		stubs.StubFunc(&_cpuAffinity, []int{0, 1}, nil)
+		giveErr := errors.New("great sadness")
+		stubs.StubFunc(&_newQueryer, nil, giveErr)
+
+		got, err := EffectiveCPUs()
+		assert.ErrorIs(t, err, giveErr)
+		assert.Equal(t, 2.0, got, "should fall back to the affinity mask")
+	})
+}
+
+func TestPidsLimit(t *testing.T) {
+	t.Run("defined", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{pids: 500}, nil)
+
+		got, defined, err := PidsLimit(nil)
+		require.NoError(t, err)
+		assert.True(t, defined, "limit should be defined")
+		assert.Equal(t, 500, got)
+	})
+
+	t.Run("undefined", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{}, nil)
+
+		_, defined, err := PidsLimit(nil)
+		require.NoError(t, err)
+		assert.False(t, defined, "limit should be undefined")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		stubs := newStubs(t)
+		giveErr := errors.New("great sadness")
+		stubs.StubFunc(&_newQueryer, nil, giveErr)
+
+		_, _, err := PidsLimit(nil)
+		assert.ErrorIs(t, err, giveErr)
+	})
+}
+
 type testQueryer struct {
-	v float64
+	v       float64
+	request float64
+	pids    int
+	cpuset  int
+	memory  int64
+	period  int
+	version int
+	paths   map[string]string
 }
 
 func (tq testQueryer) CPUQuota() (float64, bool, error) {
+	if tq.v <= 0 {
+		return -1, false, nil
+	}
 	return tq.v, true, nil
 }
 
+func (tq testQueryer) CPURequest() (float64, bool, error) {
+	if tq.request <= 0 {
+		return -1, false, nil
+	}
+	return tq.request, true, nil
+}
+
+func (tq testQueryer) PidsMax() (int, bool, error) {
+	if tq.pids <= 0 {
+		return -1, false, nil
+	}
+	return tq.pids, true, nil
+}
+
+func (tq testQueryer) CPUSetSize() (int, bool, error) {
+	if tq.cpuset <= 0 {
+		return -1, false, nil
+	}
+	return tq.cpuset, true, nil
+}
+
+func (tq testQueryer) MemoryLimit() (int64, bool, error) {
+	if tq.memory <= 0 {
+		return -1, false, nil
+	}
+	return tq.memory, true, nil
+}
+
+func (tq testQueryer) CPUPeriod() (int, bool, error) {
+	if tq.period <= 0 {
+		return -1, false, nil
+	}
+	return tq.period, true, nil
+}
+
+func (tq testQueryer) Version() int {
+	return tq.version
+}
+
+func (tq testQueryer) Paths() map[string]string {
+	return tq.paths
+}
+
 func newStubs(t *testing.T) *gostub.Stubs {
 	stubs := gostub.New()
 	t.Cleanup(stubs.Reset)
+	// Don't let the CPUs of the machine running the tests limit results.
+	stubs.StubFunc(&_cpuAffinity, nil, nil)
+	// Don't let the cgroups of the machine running the tests fail discovery.
+	stubs.StubFunc(&_newSnapshot, new(cgroups.Snapshot), nil)
 	return stubs
 }
+
+func TestCPUAffinity(t *testing.T) {
+	tests := []struct {
+		name       string
+		cpus       []int
+		err        error
+		min        int
+		want       int
+		wantStatus CPUQuotaStatus
+	}{
+		{
+			name:       "quota below affinity",
+			cpus:       []int{0, 1, 2, 3},
+			want:       2,
+			wantStatus: CPUQuotaUsed,
+		},
+		{
+			name:       "affinity binding",
+			cpus:       []int{4},
+			want:       1,
+			wantStatus: CPUQuotaAffinityUsed,
+		},
+		{
+			name:       "min above affinity",
+			cpus:       []int{4},
+			min:        2,
+			want:       2,
+			wantStatus: CPUQuotaMinUsed,
+		},
+		{
+			name:       "error ignored",
+			err:        errors.New("great sadness"),
+			want:       2,
+			wantStatus: CPUQuotaUsed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.StubFunc(&_newQueryer, testQueryer{v: 2.5}, nil)
+			stubs.StubFunc(&_cpuAffinity, tt.cpus, tt.err)
+
+			got, status, err := CPUQuotaToGOMAXPROCS(Config{Min: tt.min})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantStatus, status)
+		})
+	}
+
+	t.Run("SetCPUAffinity", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{v: 2.5}, nil)
+
+		restore := SetCPUAffinity([]int{7})
+		defer restore()
+		got, status, err := CPUQuotaToGOMAXPROCS(Config{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, got)
+		assert.Equal(t, CPUQuotaAffinityUsed, status)
+	})
+
+	t.Run("sched_getaffinity", func(t *testing.T) {
+		cpus, err := cpuAffinity()
+		require.NoError(t, err)
+		assert.Len(t, cpus, runtime.NumCPU(), "should match the CPUs available at startup")
+	})
+}
+
+func TestCapacityWeighted(t *testing.T) {
+	// CPUs 0-3 are performance cores, 4-7 efficiency cores with half the
+	// capacity, and 8 has no capacity.
+	root := t.TempDir()
+	for cpu := 0; cpu < 8; cpu++ {
+		capacity := "1024\n"
+		if cpu >= 4 {
+			capacity = "512\n"
+		}
+		dir := filepath.Join(root, "sys", "devices", "system", "cpu", fmt.Sprintf("cpu%d", cpu))
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "cpu_capacity"), []byte(capacity), 0o644))
+	}
+	invalid := filepath.Join(root, "sys", "devices", "system", "cpu", "cpu9")
+	require.NoError(t, os.MkdirAll(invalid, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(invalid, "cpu_capacity"), []byte("big\n"), 0o644))
+
+	tests := []struct {
+		name    string
+		cpus    []int
+		queryer testQueryer
+		cfg     Config
+		want    Result
+		wantErr string
+	}{
+		{
+			name:    "efficiency cores below quota",
+			cpus:    []int{4, 5, 6, 7},
+			queryer: testQueryer{v: 3},
+			cfg:     Config{CapacityWeighted: true},
+			want:    Result{GOMAXPROCS: 2, Status: CPUQuotaAffinityUsed, Quota: 3, CPUs: 4, WeightedCPUs: 2},
+		},
+		{
+			name:    "mixed cores above quota",
+			cpus:    []int{0, 1, 4, 5},
+			queryer: testQueryer{v: 2},
+			cfg:     Config{CapacityWeighted: true},
+			want:    Result{GOMAXPROCS: 2, Status: CPUQuotaUsed, Quota: 2, CPUs: 4, WeightedCPUs: 3},
+		},
+		{
+			name: "no quota",
+			cpus: []int{0, 4, 5, 6},
+			cfg:  Config{CapacityWeighted: true},
+			want: Result{GOMAXPROCS: 2, Status: CPUQuotaAffinityUsed, Quota: -1, CPUs: 4, WeightedCPUs: 2.5},
+		},
+		{
+			name: "no quota, rounded up",
+			cpus: []int{0, 4, 5, 6},
+			cfg:  Config{CapacityWeighted: true, Round: func(v float64) int { return int(math.Ceil(v)) }},
+			want: Result{GOMAXPROCS: 3, Status: CPUQuotaAffinityUsed, Quota: -1, CPUs: 4, WeightedCPUs: 2.5},
+		},
+		{
+			name: "no quota, min above weighted",
+			cpus: []int{4, 5},
+			cfg:  Config{CapacityWeighted: true, Min: 2},
+			want: Result{GOMAXPROCS: 2, Status: CPUQuotaMinUsed, Quota: -1, CPUs: 2, WeightedCPUs: 1},
+		},
+		{
+			name: "no quota, performance cores",
+			cpus: []int{0, 1},
+			cfg:  Config{CapacityWeighted: true},
+			want: Result{GOMAXPROCS: -1, Status: CPUQuotaUndefined, Quota: -1, CPUs: 2, WeightedCPUs: 2},
+		},
+		{
+			name:    "single efficiency core",
+			cpus:    []int{4},
+			queryer: testQueryer{v: 2},
+			cfg:     Config{CapacityWeighted: true},
+			want:    Result{GOMAXPROCS: 1, Status: CPUQuotaAffinityUsed, Quota: 2, CPUs: 1, WeightedCPUs: 0.5},
+		},
+		{
+			name:    "capacity unknown",
+			cpus:    []int{7, 8},
+			queryer: testQueryer{v: 3},
+			cfg:     Config{CapacityWeighted: true},
+			want:    Result{GOMAXPROCS: 2, Status: CPUQuotaAffinityUsed, Quota: 3, CPUs: 2},
+		},
+		{
+			name:    "disabled",
+			cpus:    []int{4, 5, 6, 7},
+			queryer: testQueryer{v: 3},
+			want:    Result{GOMAXPROCS: 3, Status: CPUQuotaUsed, Quota: 3, CPUs: 4},
+		},
+		{
+			name:    "invalid capacity",
+			cpus:    []int{0, 9},
+			cfg:     Config{CapacityWeighted: true},
+			wantErr: `invalid CPU capacity "big" in ` + filepath.Join(invalid, "cpu_capacity"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.Stub(&_root, root)
+			stubs.StubFunc(&_numCPU, len(tt.cpus))
+			stubs.StubFunc(&_cpuAffinity, tt.cpus, nil)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+
+			got, err := DetectGOMAXPROCS(tt.cfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPhysicalCores(t *testing.T) {
+	// CPUs 0-3 are the first hardware threads of 4 cores, and 4-7 their
+	// siblings. CPUs 8-9 share a core on an older kernel. The topology of CPU
+	// 10 is unknown, and that of 11 invalid.
+	root := t.TempDir()
+	writeTopology := func(cpu int, name, threads string) {
+		dir := filepath.Join(root, "sys", "devices", "system", "cpu", fmt.Sprintf("cpu%d", cpu), "topology")
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(threads+"\n"), 0o644))
+	}
+	for core := 0; core < 4; core++ {
+		threads := fmt.Sprintf("%d,%d", core, core+4)
+		writeTopology(core, "core_cpus_list", threads)
+		writeTopology(core+4, "core_cpus_list", threads)
+		// Older lists are ignored in favor of core_cpus_list.
+		writeTopology(core, "thread_siblings_list", "0-7")
+	}
+	writeTopology(8, "thread_siblings_list", "8-9")
+	writeTopology(9, "thread_siblings_list", "8-9")
+	writeTopology(11, "core_cpus_list", "")
+
+	tests := []struct {
+		name    string
+		cpus    []int
+		queryer testQueryer
+		cfg     Config
+		want    Result
+		wantErr string
+	}{
+		{
+			name:    "cores below quota",
+			cpus:    []int{0, 1, 4, 5},
+			queryer: testQueryer{v: 3},
+			cfg:     Config{PhysicalCores: true},
+			want:    Result{GOMAXPROCS: 2, Status: CPUQuotaCoresUsed, Quota: 3, CPUs: 4, PhysicalCores: 2},
+		},
+		{
+			name:    "quota below cores",
+			cpus:    []int{0, 1, 2, 3, 4, 5, 6, 7},
+			queryer: testQueryer{v: 3},
+			cfg:     Config{PhysicalCores: true},
+			want:    Result{GOMAXPROCS: 3, Status: CPUQuotaUsed, Quota: 3, CPUs: 8, PhysicalCores: 4},
+		},
+		{
+			name:    "single thread of each core",
+			cpus:    []int{0, 1, 2},
+			queryer: testQueryer{v: 4},
+			cfg:     Config{PhysicalCores: true},
+			want:    Result{GOMAXPROCS: 3, Status: CPUQuotaAffinityUsed, Quota: 4, CPUs: 3, PhysicalCores: 3},
+		},
+		{
+			name: "no quota",
+			cpus: []int{0, 1, 2, 3, 4, 5, 6, 7},
+			cfg:  Config{PhysicalCores: true},
+			want: Result{GOMAXPROCS: 4, Status: CPUQuotaCoresUsed, Quota: -1, CPUs: 8, PhysicalCores: 4},
+		},
+		{
+			name: "no quota, max below cores",
+			cpus: []int{0, 1, 2, 3, 4, 5, 6, 7},
+			cfg:  Config{PhysicalCores: true, Max: 3},
+			want: Result{GOMAXPROCS: 3, Status: CPUQuotaMaxUsed, Quota: -1, CPUs: 8, PhysicalCores: 4},
+		},
+		{
+			name: "thread_siblings_list",
+			cpus: []int{8, 9},
+			cfg:  Config{PhysicalCores: true},
+			want: Result{GOMAXPROCS: 1, Status: CPUQuotaCoresUsed, Quota: -1, CPUs: 2, PhysicalCores: 1},
+		},
+		{
+			name: "with capacity",
+			cpus: []int{0, 4, 8, 9},
+			cfg:  Config{PhysicalCores: true, CapacityWeighted: true},
+			want: Result{GOMAXPROCS: 2, Status: CPUQuotaCoresUsed, Quota: -1, CPUs: 4, PhysicalCores: 2},
+		},
+		{
+			name:    "topology unknown",
+			cpus:    []int{0, 4, 10},
+			queryer: testQueryer{v: 4},
+			cfg:     Config{PhysicalCores: true},
+			want:    Result{GOMAXPROCS: 3, Status: CPUQuotaAffinityUsed, Quota: 4, CPUs: 3},
+		},
+		{
+			name:    "disabled",
+			cpus:    []int{0, 1, 4, 5},
+			queryer: testQueryer{v: 3},
+			want:    Result{GOMAXPROCS: 3, Status: CPUQuotaUsed, Quota: 3, CPUs: 4},
+		},
+		{
+			name:    "invalid topology",
+			cpus:    []int{0, 11},
+			cfg:     Config{PhysicalCores: true},
+			wantErr: "empty list of core CPUs in " + filepath.Join(root, "sys", "devices", "system", "cpu", "cpu11", "topology", "core_cpus_list"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.Stub(&_root, root)
+			stubs.StubFunc(&_numCPU, len(tt.cpus))
+			stubs.StubFunc(&_cpuAffinity, tt.cpus, nil)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+
+			got, err := DetectGOMAXPROCS(tt.cfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetRoot(t *testing.T) {
+	stubs := newStubs(t)
+	stubs.Stub(&_newSnapshot, cgroups.NewSnapshotWithRoot)
+
+	restore := SetRoot(filepath.Join("..", "cgroups", "testdata", "root", "v1"))
+	t.Cleanup(restore)
+
+	got, status, err := CPUQuotaToGOMAXPROCS(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, CPUQuotaUsed, status)
+	assert.Equal(t, 3, got)
+
+	restore()
+	assert.Equal(t, "/", _root, "restore should reset the root")
+}
+
+func TestDetectGOMAXPROCS(t *testing.T) {
+	t.Run("quota", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{v: 2.7}, nil)
+
+		got, err := DetectGOMAXPROCS(Config{})
+		require.NoError(t, err)
+		assert.Equal(t, Result{GOMAXPROCS: 2, Status: CPUQuotaUsed, Quota: 2.7}, got)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_numCPU, 8)
+		stubs.StubFunc(&_newQueryer, testQueryer{request: 1.5}, nil)
+
+		got, err := DetectGOMAXPROCS(Config{RequestMultiplier: 2})
+		require.NoError(t, err)
+		assert.Equal(t, Result{GOMAXPROCS: 3, Status: CPUQuotaRequestUsed, Quota: 3}, got)
+	})
+
+	t.Run("undefined", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_newQueryer, testQueryer{}, nil)
+
+		got, err := DetectGOMAXPROCS(Config{})
+		require.NoError(t, err)
+		assert.Equal(t, Result{GOMAXPROCS: -1, Status: CPUQuotaUndefined, Quota: -1}, got)
+	})
+}
+
+func TestMax(t *testing.T) {
+	tests := []struct {
+		name    string
+		queryer testQueryer
+		numCPU  int
+		cfg     Config
+		want    Result
+	}{
+		{
+			name:    "quota below max",
+			queryer: testQueryer{v: 2.7},
+			cfg:     Config{Max: 4},
+			want:    Result{GOMAXPROCS: 2, Status: CPUQuotaUsed, Quota: 2.7},
+		},
+		{
+			name:    "quota above max",
+			queryer: testQueryer{v: 6},
+			cfg:     Config{Max: 4},
+			want:    Result{GOMAXPROCS: 4, Status: CPUQuotaMaxUsed, Quota: 6},
+		},
+		{
+			name:    "min above max",
+			queryer: testQueryer{v: 6},
+			cfg:     Config{Min: 5, Max: 4},
+			want:    Result{GOMAXPROCS: 5, Status: CPUQuotaMinUsed, Quota: 6},
+		},
+		{
+			name:   "no quota, CPUs above max",
+			numCPU: 64,
+			cfg:    Config{Max: 32},
+			want:   Result{GOMAXPROCS: 32, Status: CPUQuotaMaxUsed, Quota: -1},
+		},
+		{
+			name:   "no quota, CPUs below max",
+			numCPU: 16,
+			cfg:    Config{Max: 32},
+			want:   Result{GOMAXPROCS: -1, Status: CPUQuotaUndefined, Quota: -1},
+		},
+		{
+			name:   "no quota, min above max",
+			numCPU: 64,
+			cfg:    Config{Min: 40, Max: 32},
+			want:   Result{GOMAXPROCS: 40, Status: CPUQuotaMinUsed, Quota: -1},
+		},
+		{
+			name:   "no request, CPUs above max",
+			numCPU: 64,
+			cfg:    Config{Max: 32, RequestMultiplier: 1},
+			want:   Result{GOMAXPROCS: 32, Status: CPUQuotaMaxUsed, Quota: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := newStubs(t)
+			stubs.StubFunc(&_numCPU, tt.numCPU)
+			stubs.StubFunc(&_newQueryer, tt.queryer, nil)
+
+			got, err := DetectGOMAXPROCS(tt.cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDetectFacts(t *testing.T) {
+	t.Run("cgroups", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_hostCPUs, 96)
+		paths := map[string]string{"cgroup2": "/sys/fs/cgroup/app"}
+		stubs.StubFunc(&_newQueryer, testQueryer{v: 2.5, period: 100000, cpuset: 4, memory: 1 << 30, version: 2, paths: paths}, nil)
+
+		got, err := DetectFacts(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, Facts{
+			Quota:         2.5,
+			CPUPeriod:     100000,
+			CPUSetSize:    4,
+			HostCPUs:      96,
+			CGroupVersion: 2,
+			CGroupPaths:   paths,
+			MemoryLimit:   1 << 30,
+		}, got)
+	})
+
+	t.Run("undefined", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_hostCPUs, 8)
+		stubs.StubFunc(&_newQueryer, undefinedQueryer{}, nil)
+
+		got, err := DetectFacts(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, Facts{Quota: -1, CPUPeriod: -1, CPUSetSize: -1, HostCPUs: 8, MemoryLimit: -1}, got)
+	})
+
+	t.Run("source", func(t *testing.T) {
+		stubs := newStubs(t)
+		stubs.StubFunc(&_hostCPUs, 8)
+		stubs.StubFunc(&_newQueryer, testQueryer{v: 2.5, version: 1}, nil)
+
+		got, err := DetectFacts(testQueryer{v: 1.5}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1.5, got.Quota, "quota should come from the source")
+		assert.Equal(t, 1, got.CGroupVersion)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		stubs := newStubs(t)
+		giveErr := errors.New("great sadness")
+		stubs.StubFunc(&_newQueryer, nil, giveErr)
+
+		_, err := DetectFacts(nil, nil)
+		assert.ErrorIs(t, err, giveErr)
+	})
+}
+
+func TestHostCPUs(t *testing.T) {
+	stubs := newStubs(t)
+	stubs.StubFunc(&_numCPU, 3)
+
+	root := t.TempDir()
+	stubs.Stub(&_root, root)
+	assert.Equal(t, 3, hostCPUs(), "should fall back to NumCPU")
+
+	dir := filepath.Join(root, "sys", "devices", "system", "cpu")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "online"), []byte("0-63,96-127\n"), 0o644))
+	assert.Equal(t, 96, hostCPUs())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "online"), []byte("all\n"), 0o644))
+	assert.Equal(t, 3, hostCPUs(), "should fall back to NumCPU")
+}
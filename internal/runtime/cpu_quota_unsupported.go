@@ -23,9 +23,29 @@
 
 package runtime
 
-// CPUQuotaToGOMAXPROCS converts the CPU quota applied to the calling process
-// to a valid GOMAXPROCS value. This is Linux-specific and not supported in the
-// current OS.
-func CPUQuotaToGOMAXPROCS(_ int, _ func(v float64) int) (int, CPUQuotaStatus, error) {
-	return -1, CPUQuotaUndefined, nil
+// newQueryer returns the queryer for the cgroups of the calling process.
+// Cgroups are Linux-specific, so this always returns a queryer for which
+// every parameter is undefined.
+func newQueryer(func(error)) (queryer, error) {
+	return undefinedQueryer{}, nil
+}
+
+func newCGroupsV1(onInvalidLine func(error)) (queryer, error) {
+	return newQueryer(onInvalidLine)
+}
+
+func newCGroupsV2(onInvalidLine func(error)) (queryer, error) {
+	return newQueryer(onInvalidLine)
+}
+
+// hostCPUs returns the number of CPUs of the host. Reading the online CPUs is
+// Linux-specific, so this returns runtime.NumCPU().
+func hostCPUs() int {
+	return _numCPU()
+}
+
+// cpuAffinity returns the CPUs the calling process may run on. This is
+// Linux-specific and not supported in the current OS.
+func cpuAffinity() ([]int, error) {
+	return nil, nil
 }
@@ -0,0 +1,122 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// _cpuQuantitySuffixes maps the suffixes of Kubernetes CPU quantities to the
+// number of CPUs they stand for.
+var _cpuQuantitySuffixes = map[string]float64{
+	"n": 1e-9,
+	"u": 1e-6,
+	"m": 1e-3,
+}
+
+// DownwardAPIEnvSource returns a QuotaSource that reads the CPU limit of a
+// Kubernetes container from the environment variable name, as projected by
+// the downward API with the given divisor. Both the value and the divisor are
+// CPU quantities, such as "2", "0.5" or "1500m", and an empty divisor is the
+// same as "1". The quota is undefined if the variable is unset or empty.
+func DownwardAPIEnvSource(name, divisor string) QuotaSource {
+	return downwardAPISource{
+		desc:    fmt.Sprintf("environment variable %v", name),
+		divisor: divisor,
+		read: func() (string, bool, error) {
+			v := os.Getenv(name)
+			return v, v != "", nil
+		},
+	}
+}
+
+// DownwardAPIFileSource returns a QuotaSource that reads the CPU limit of a
+// Kubernetes container from the file at path, as projected by the downward
+// API with the given divisor, interpreted like DownwardAPIEnvSource does. The
+// quota is undefined if the file doesn't exist.
+func DownwardAPIFileSource(path, divisor string) QuotaSource {
+	return downwardAPISource{
+		desc:    fmt.Sprintf("file %v", path),
+		divisor: divisor,
+		read: func() (string, bool, error) {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return "", false, nil
+				}
+				return "", false, err
+			}
+			return string(b), true, nil
+		},
+	}
+}
+
+type downwardAPISource struct {
+	desc    string
+	divisor string
+	read    func() (string, bool, error)
+}
+
+func (s downwardAPISource) CPUQuota() (float64, bool, error) {
+	text, defined, err := s.read()
+	if !defined || err != nil {
+		return -1, false, err
+	}
+
+	quota, err := parseCPUQuantity(text)
+	if err != nil {
+		return -1, false, fmt.Errorf("CPU limit from %v: %w", s.desc, err)
+	}
+	if s.divisor != "" {
+		divisor, err := parseCPUQuantity(s.divisor)
+		if err != nil {
+			return -1, false, fmt.Errorf("CPU limit divisor: %w", err)
+		}
+		quota *= divisor
+	}
+	return quota, true, nil
+}
+
+// parseCPUQuantity parses a positive Kubernetes CPU quantity, such as "2",
+// "0.5" or "1500m", and returns it in CPUs. Surrounding whitespace is ignored.
+func parseCPUQuantity(s string) (float64, error) {
+	text := strings.TrimSpace(s)
+	scale := 1.0
+	if n := len(text); n > 0 {
+		if suffixScale, ok := _cpuQuantitySuffixes[text[n-1:]]; ok {
+			text, scale = text[:n-1], suffixScale
+		}
+	}
+
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return -1, fmt.Errorf("invalid CPU quantity %q: %w", s, errors.Unwrap(err))
+	}
+	if !(v > 0) || math.IsInf(v, 0) {
+		return -1, fmt.Errorf("invalid CPU quantity %q: must be a positive number", s)
+	}
+	return v * scale, nil
+}
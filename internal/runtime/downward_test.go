@@ -0,0 +1,135 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPUQuantity(t *testing.T) {
+	tests := []struct {
+		give    string
+		want    float64
+		wantErr string
+	}{
+		{give: "2", want: 2},
+		{give: "0.5", want: 0.5},
+		{give: "1500m", want: 1.5},
+		{give: "250000u", want: 0.25},
+		{give: "100000000n", want: 0.1},
+		{give: " 3\n", want: 3},
+		{give: "", wantErr: `invalid CPU quantity "": invalid syntax`},
+		{give: "m", wantErr: `invalid CPU quantity "m": invalid syntax`},
+		{give: "2 cores", wantErr: `invalid CPU quantity "2 cores": invalid syntax`},
+		{give: "1Gi", wantErr: `invalid CPU quantity "1Gi": invalid syntax`},
+		{give: "0", wantErr: "must be a positive number"},
+		{give: "-1", wantErr: "must be a positive number"},
+		{give: "NaN", wantErr: "must be a positive number"},
+		{give: "Inf", wantErr: "must be a positive number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.give, func(t *testing.T) {
+			got, err := parseCPUQuantity(tt.give)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestDownwardAPIEnvSource(t *testing.T) {
+	const name = "AUTOMAXPROCS_TEST_CPU_LIMIT"
+
+	tests := []struct {
+		name        string
+		value       string
+		divisor     string
+		want        float64
+		wantDefined bool
+		wantErr     string
+	}{
+		{name: "unset", want: -1},
+		{name: "millicores", value: "1500m", want: 1.5, wantDefined: true},
+		{name: "cores", value: "2", divisor: "1", want: 2, wantDefined: true},
+		{name: "divisor", value: "1500", divisor: "1m", want: 1.5, wantDefined: true},
+		{name: "invalid", value: "lots", want: -1, wantErr: "CPU limit from environment variable " + name},
+		{name: "invalid divisor", value: "1500", divisor: "1Mi", want: -1, wantErr: "CPU limit divisor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(name, tt.value)
+
+			quota, defined, err := DownwardAPIEnvSource(name, tt.divisor).CPUQuota()
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.InDelta(t, tt.want, quota, 1e-9)
+			assert.Equal(t, tt.wantDefined, defined)
+		})
+	}
+}
+
+func TestDownwardAPIFileSource(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cpu_limit")
+
+	t.Run("nonexistent", func(t *testing.T) {
+		_, defined, err := DownwardAPIFileSource(path, "").CPUQuota()
+		require.NoError(t, err)
+		assert.False(t, defined, "quota should be undefined")
+	})
+
+	t.Run("millicores", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("750\n"), 0o644))
+
+		quota, defined, err := DownwardAPIFileSource(path, "1m").CPUQuota()
+		require.NoError(t, err)
+		assert.True(t, defined, "quota should be defined")
+		assert.InDelta(t, 0.75, quota, 1e-9)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+
+		_, _, err := DownwardAPIFileSource(path, "").CPUQuota()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CPU limit from file "+path)
+	})
+
+	t.Run("unreadable", func(t *testing.T) {
+		_, _, err := DownwardAPIFileSource(dir, "").CPUQuota()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+// Facts describes the CPU and memory limits of the calling process.
+type Facts struct {
+	// Quota is the CPU quota, in CPUs, or -1 if none is defined.
+	Quota float64
+	// CPUPeriod is the period over which the CPU quota applies, in
+	// microseconds, or -1 if unknown. It may be defined without a quota.
+	CPUPeriod int
+	// CPUSetSize is the number of CPUs in the cpuset of the cgroup, or -1 if
+	// none is defined.
+	CPUSetSize int
+	// HostCPUs is the number of online CPUs of the host, regardless of the
+	// cpuset and affinity mask of the process.
+	HostCPUs int
+	// CGroupVersion is 1 or 2 for cgroups v1 or v2, and 0 outside of
+	// cgroups.
+	CGroupVersion int
+	// CGroupPaths are the paths of the cgroups of the process, by cgroups
+	// v1 subsystem, or "cgroup2" for cgroups v2.
+	CGroupPaths map[string]string
+	// MemoryLimit is the memory limit of the cgroup, in bytes, or -1 if none
+	// is defined.
+	MemoryLimit int64
+}
+
+// DetectFacts returns the Facts of the calling process. The quota is read from
+// source, or from the cgroups of the calling process if source is nil. If
+// onInvalidLine is set, lines that can't be parsed are passed to it and
+// skipped, as with Config.OnInvalidLine.
+func DetectFacts(source QuotaSource, onInvalidLine func(error)) (Facts, error) {
+	facts := Facts{Quota: -1, CPUPeriod: -1, CPUSetSize: -1, HostCPUs: _hostCPUs(), MemoryLimit: -1}
+	cgroups, err := _newQueryer(onInvalidLine)
+	if err != nil {
+		return facts, err
+	}
+	facts.CGroupVersion, facts.CGroupPaths = cgroups.Version(), cgroups.Paths()
+
+	if source == nil {
+		source = cgroups
+	}
+	quota, defined, err := source.CPUQuota()
+	if err != nil {
+		return facts, err
+	}
+	if defined {
+		facts.Quota = quota
+	}
+	period, defined, err := cgroups.CPUPeriod()
+	if err != nil {
+		return facts, err
+	}
+	if defined {
+		facts.CPUPeriod = period
+	}
+	size, defined, err := cgroups.CPUSetSize()
+	if err != nil {
+		return facts, err
+	}
+	if defined {
+		facts.CPUSetSize = size
+	}
+	limit, defined, err := cgroups.MemoryLimit()
+	if err != nil {
+		return facts, err
+	}
+	if defined {
+		facts.MemoryLimit = limit
+	}
+	return facts, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// _readBuildInfo reads the build settings, which include the GODEBUG
+// defaults of the main module.
+var _readBuildInfo = debug.ReadBuildInfo
+
+// GoRuntime describes how the Go runtime sets GOMAXPROCS by itself.
+type GoRuntime struct {
+	// Version is the version of the Go runtime, as in runtime.Version.
+	Version string
+	// ContainerAware is set if the runtime defaults GOMAXPROCS to the CPU
+	// limit of the cgroup of the process, as Go 1.25 and later do on Linux
+	// unless GODEBUG has containermaxprocs=0.
+	ContainerAware bool
+	// Updates is set if the runtime also updates GOMAXPROCS when the CPU
+	// limit or the number of CPUs change, unless GODEBUG has
+	// updatemaxprocs=0.
+	Updates bool
+}
+
+// DetectGoRuntime returns how the Go runtime sets GOMAXPROCS by itself,
+// assuming nothing else set it. The runtime does neither if the GOMAXPROCS
+// environment variable is a positive integer, which it uses instead.
+func DetectGoRuntime() GoRuntime {
+	rt := GoRuntime{Version: runtime.Version()}
+	if !_goRuntimeSetsMaxProcs {
+		return rt
+	}
+	if n, err := strconv.Atoi(os.Getenv("GOMAXPROCS")); err == nil && n > 0 {
+		return rt
+	}
+
+	rt.ContainerAware = runtime.GOOS == "linux" && godebug("containermaxprocs") != "0"
+	rt.Updates = godebug("updatemaxprocs") != "0"
+	return rt
+}
+
+// godebug returns the value of the GODEBUG setting key, which the GODEBUG
+// environment variable overrides from the defaults of the main module, set by
+// its go version and go:debug directives.
+func godebug(key string) string {
+	if v, ok := lookupGODEBUG(os.Getenv("GODEBUG"), key); ok {
+		return v
+	}
+	if info, ok := _readBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "DefaultGODEBUG" {
+				v, _ := lookupGODEBUG(s.Value, key)
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// lookupGODEBUG returns the value of key in a comma-separated list of
+// key=value settings, in which the last setting wins.
+func lookupGODEBUG(settings, key string) (value string, ok bool) {
+	for _, s := range strings.Split(settings, ",") {
+		if k, v, found := strings.Cut(s, "="); found && k == key {
+			value, ok = v, true
+		}
+	}
+	return value, ok
+}
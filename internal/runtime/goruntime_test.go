@@ -0,0 +1,128 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupGODEBUG(t *testing.T) {
+	tests := []struct {
+		settings string
+		want     string
+		wantOK   bool
+	}{
+		{settings: ""},
+		{settings: "panicnil=1,updatemaxprocs=0", want: "0", wantOK: true},
+		{settings: "updatemaxprocs=0,updatemaxprocs=1", want: "1", wantOK: true},
+		{settings: "updatemaxprocs", wantOK: false},
+		{settings: "containermaxprocs=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.settings, func(t *testing.T) {
+			got, ok := lookupGODEBUG(tt.settings, "updatemaxprocs")
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantOK, ok)
+		})
+	}
+}
+
+func TestDetectGoRuntime(t *testing.T) {
+	buildInfo := func(defaults string) func() (*debug.BuildInfo, bool) {
+		return func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{Settings: []debug.BuildSetting{
+				{Key: "-compiler", Value: "gc"},
+				{Key: "DefaultGODEBUG", Value: defaults},
+			}}, true
+		}
+	}
+	linux := runtime.GOOS == "linux"
+
+	tests := []struct {
+		name     string
+		supports bool
+		defaults string
+		godebug  string
+		env      string
+		want     GoRuntime
+	}{
+		{
+			name:     "old runtime",
+			supports: false,
+			want:     GoRuntime{},
+		},
+		{
+			name:     "new runtime",
+			supports: true,
+			want:     GoRuntime{ContainerAware: linux, Updates: true},
+		},
+		{
+			name:     "old main module",
+			supports: true,
+			defaults: "containermaxprocs=0,panicnil=1,updatemaxprocs=0",
+			want:     GoRuntime{},
+		},
+		{
+			name:     "GODEBUG overrides defaults",
+			supports: true,
+			defaults: "containermaxprocs=0,updatemaxprocs=0",
+			godebug:  "containermaxprocs=1",
+			want:     GoRuntime{ContainerAware: linux},
+		},
+		{
+			name:     "updates disabled",
+			supports: true,
+			godebug:  "updatemaxprocs=0",
+			want:     GoRuntime{ContainerAware: linux},
+		},
+		{
+			name:     "GOMAXPROCS set",
+			supports: true,
+			env:      "4",
+			want:     GoRuntime{},
+		},
+		{
+			name:     "GOMAXPROCS invalid",
+			supports: true,
+			env:      "lots",
+			want:     GoRuntime{ContainerAware: linux, Updates: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubs := gostub.Stub(&_goRuntimeSetsMaxProcs, tt.supports)
+			defer stubs.Reset()
+			stubs.Stub(&_readBuildInfo, buildInfo(tt.defaults))
+			t.Setenv("GODEBUG", tt.godebug)
+			t.Setenv("GOMAXPROCS", tt.env)
+
+			tt.want.Version = runtime.Version()
+			assert.Equal(t, tt.want, DetectGoRuntime())
+		})
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"runtime"
+	"sync/atomic"
+)
+
+var (
+	// _root is the directory that `/proc` and cgroup file system paths are
+	// resolved against. Only tests change it.
+	_root = "/"
+	// _cpuAffinity returns the CPUs the calling process may run on.
+	_cpuAffinity = cpuAffinity
+
+	_newQueryer   = newQueryer
+	_newCGroupsV1 = newCGroupsV1
+	_newCGroupsV2 = newCGroupsV2
+	_numCPU       = runtime.NumCPU
+	_hostCPUs     = hostCPUs
+
+	// _hooksVersion counts the changes made by SetRoot, SetCPUAffinity and
+	// the functions they return.
+	_hooksVersion int64
+)
+
+// HooksVersion returns a number that changes whenever SetRoot,
+// SetCPUAffinity or the functions they return change what the detection
+// functions see, so that callers caching their results can detect again.
+func HooksVersion() int64 {
+	return atomic.LoadInt64(&_hooksVersion)
+}
+
+// SetRoot changes the directory that `/proc` and cgroup file system paths are
+// resolved against, and returns a function that restores the previous one.
+//
+// It's intended for tests that simulate container CPU limits with a fake file
+// system tree, and must not be called concurrently with CPUQuotaToGOMAXPROCS.
+func SetRoot(root string) (restore func()) {
+	prev := _root
+	_root = root
+	atomic.AddInt64(&_hooksVersion, 1)
+	return func() {
+		_root = prev
+		atomic.AddInt64(&_hooksVersion, 1)
+	}
+}
+
+// SetCPUAffinity makes CPUQuotaToGOMAXPROCS behave as if the calling process
+// may only run on the given CPUs, and returns a function that restores the
+// previous behavior.
+//
+// It's intended for tests, and must not be called concurrently with
+// CPUQuotaToGOMAXPROCS.
+func SetCPUAffinity(cpus []int) (restore func()) {
+	prev := _cpuAffinity
+	_cpuAffinity = func() ([]int, error) { return cpus, nil }
+	atomic.AddInt64(&_hooksVersion, 1)
+	return func() {
+		_cpuAffinity = prev
+		atomic.AddInt64(&_hooksVersion, 1)
+	}
+}
@@ -22,6 +22,12 @@ package runtime
 
 import "math"
 
+// QuotaSource provides the CPU quota available to the calling process.
+type QuotaSource interface {
+	// CPUQuota returns the CPU quota, in CPUs, and whether one is defined.
+	CPUQuota() (float64, bool, error)
+}
+
 // CPUQuotaStatus presents the status of how CPU quota is used
 type CPUQuotaStatus int
 
@@ -32,8 +38,322 @@ const (
 	CPUQuotaUsed
 	// CPUQuotaMinUsed is returned when CPU quota is smaller than the min value
 	CPUQuotaMinUsed
+	// CPUQuotaRequestUsed is returned when no CPU quota is defined and the
+	// value is estimated from the CPU request instead
+	CPUQuotaRequestUsed
+	// CPUQuotaAffinityUsed is returned when the value derived from the CPU
+	// quota or request exceeds the number of CPUs in the affinity mask of the
+	// process, which is used instead
+	CPUQuotaAffinityUsed
+	// CPUQuotaMaxUsed is returned when the value derived from the CPU quota,
+	// or the number of CPUs if no quota is defined, exceeds the max value
+	CPUQuotaMaxUsed
+	// CPUQuotaCoresUsed is returned when the value derived from the CPU
+	// quota exceeds the number of physical cores the process may run on,
+	// which is used instead
+	CPUQuotaCoresUsed
 )
 
+// Config configures how CPUQuotaToGOMAXPROCS derives GOMAXPROCS.
+type Config struct {
+	// Min is the minimum GOMAXPROCS value. Values below 1 are ignored.
+	Min int
+	// Max, if positive, is the maximum GOMAXPROCS value. Unlike the quota,
+	// it applies even if no quota is defined, when it's compared with
+	// runtime.NumCPU(). Min takes precedence over it.
+	Max int
+	// Round converts the CPU quota from float to int. If nil,
+	// DefaultRoundFunc is used.
+	Round func(v float64) int
+	// RequestMultiplier, if positive, enables estimating GOMAXPROCS as this
+	// multiple of the CPU request (from cpu.weight or cpu.shares) when no CPU
+	// quota is defined. The estimate never exceeds runtime.NumCPU().
+	RequestMultiplier float64
+	// ReserveCPUs and ReserveFraction reserve part of the CPU quota, or of the
+	// estimate from the CPU request, for work other than Go code: a number of
+	// CPUs, plus a fraction of the quota. The reservation is subtracted from
+	// the quota before rounding, but never takes GOMAXPROCS below Min.
+	// Negative values are ignored.
+	ReserveCPUs     float64
+	ReserveFraction float64
+	// Source provides the CPU quota. If nil, the quota is read from the
+	// cgroups of the calling process.
+	Source QuotaSource
+	// CapacityWeighted weighs the CPUs of the affinity mask by their
+	// capacity, relative to the largest CPUs of the host, when limiting
+	// GOMAXPROCS to them. For example, 4 efficiency cores with half the
+	// capacity of the performance cores count as 2 CPUs. The weighted count
+	// is rounded with Round, and limits GOMAXPROCS even if no quota is
+	// defined. It's ignored unless the kernel reports the capacity of every
+	// CPU.
+	CapacityWeighted bool
+	// PhysicalCores limits GOMAXPROCS to the number of physical cores of the
+	// CPUs in the affinity mask, rather than their hardware threads, even if
+	// no quota is defined. A core counts if any of its threads is in the
+	// mask. It's ignored unless the kernel reports the topology of every CPU.
+	PhysicalCores bool
+	// OnInvalidLine, if set, makes reading the cgroups of the calling process
+	// lenient: lines of `/proc/self/mountinfo` and `/proc/self/cgroup` that
+	// can't be parsed are passed to it and skipped. It doesn't apply to
+	// Source.
+	OnInvalidLine func(error)
+}
+
+// Result describes the GOMAXPROCS value derived from the CPU quota.
+type Result struct {
+	// GOMAXPROCS is the derived value, or -1 if Status is CPUQuotaUndefined.
+	GOMAXPROCS int
+	Status     CPUQuotaStatus
+	// Quota is the CPU quota, or the estimate from the CPU request, before
+	// rounding. It's -1 if neither is defined.
+	Quota float64
+	// Reserved is the part of Quota reserved by Config.ReserveCPUs and
+	// Config.ReserveFraction, which GOMAXPROCS doesn't account for.
+	Reserved float64
+	// CPUs is the number of CPUs in the affinity mask of the process, or 0
+	// if unknown, and WeightedCPUs their capacity-weighted count with
+	// Config.CapacityWeighted, or 0 if unknown or not computed.
+	CPUs         int
+	WeightedCPUs float64
+	// PhysicalCores is the number of physical cores of the CPUs with
+	// Config.PhysicalCores, or 0 if unknown or not counted.
+	PhysicalCores int
+}
+
+// CPUQuotaToGOMAXPROCS converts the CPU quota applied to the calling process
+// to a valid GOMAXPROCS value. See DetectGOMAXPROCS.
+func CPUQuotaToGOMAXPROCS(cfg Config) (int, CPUQuotaStatus, error) {
+	r, err := DetectGOMAXPROCS(cfg)
+	return r.GOMAXPROCS, r.Status, err
+}
+
+// DetectGOMAXPROCS converts the CPU quota applied to the calling process to a
+// valid GOMAXPROCS value. The quota is converted from float to int using
+// cfg.Round. If no quota is defined and cfg.RequestMultiplier is positive, the
+// value is estimated from the CPU request instead. The result never exceeds
+// the number of CPUs in the affinity mask of the process, weighted by
+// capacity if cfg.CapacityWeighted is set, the number of physical cores of
+// these CPUs if cfg.PhysicalCores is set, or cfg.Max, unless cfg.Min does.
+// The CPUs reserved by cfg are subtracted from the quota before
+// rounding.
+//
+// Reading the quota and request from cgroups is Linux-specific, and they're
+// always undefined in other OSes.
+func DetectGOMAXPROCS(cfg Config) (Result, error) {
+	undefined := Result{GOMAXPROCS: -1, Status: CPUQuotaUndefined, Quota: -1}
+	round := cfg.Round
+	if round == nil {
+		round = DefaultRoundFunc
+	}
+	source := cfg.Source
+	if source == nil {
+		source = queryerSource(func() (queryer, error) { return _newQueryer(cfg.OnInvalidLine) })
+	}
+
+	cpus, err := detectCPUs(cfg, round)
+	if err != nil {
+		return undefined, err
+	}
+
+	status := CPUQuotaUsed
+	quota, defined, err := source.CPUQuota()
+	if err != nil {
+		return undefined, err
+	}
+	if !defined {
+		if cfg.RequestMultiplier <= 0 {
+			return withoutQuota(cfg, cpus), nil
+		}
+
+		cgroups, err := _newQueryer(cfg.OnInvalidLine)
+		if err != nil {
+			return undefined, err
+		}
+		request, defined, err := cgroups.CPURequest()
+		if err != nil {
+			return undefined, err
+		}
+		if !defined {
+			return withoutQuota(cfg, cpus), nil
+		}
+
+		// Unlike a quota, a request doesn't cap CPU usage, so the estimate
+		// may exceed it but never the number of usable CPUs.
+		status = CPUQuotaRequestUsed
+		quota = request * cfg.RequestMultiplier
+		if numCPU := float64(_numCPU()); quota > numCPU {
+			quota = numCPU
+		}
+	}
+
+	reserved := reservation(cfg, quota)
+	maxProcs := round(quota - reserved)
+	if cpus.limit > 0 && maxProcs > cpus.limit {
+		maxProcs, status = cpus.limit, cpus.status
+	}
+	if cfg.Max > 0 && maxProcs > cfg.Max {
+		maxProcs, status = cfg.Max, CPUQuotaMaxUsed
+	}
+	if cfg.Min > 0 && maxProcs < cfg.Min {
+		maxProcs, status = cfg.Min, CPUQuotaMinUsed
+	}
+	return Result{
+		GOMAXPROCS:    maxProcs,
+		Status:        status,
+		Quota:         quota,
+		Reserved:      reserved,
+		CPUs:          cpus.count,
+		WeightedCPUs:  cpus.weighted,
+		PhysicalCores: cpus.cores,
+	}, nil
+}
+
+// withoutQuota returns the result if neither a CPU quota nor a request is
+// defined: undefined, unless the capacity-weighted CPUs, the physical cores or
+// cfg.Max are below the number of CPUs.
+func withoutQuota(cfg Config, cpus cpuLimit) Result {
+	r := Result{
+		GOMAXPROCS:    -1,
+		Status:        CPUQuotaUndefined,
+		Quota:         -1,
+		CPUs:          cpus.count,
+		WeightedCPUs:  cpus.weighted,
+		PhysicalCores: cpus.cores,
+	}
+	procs := _numCPU()
+	// Unlike the number of CPUs, the Go runtime doesn't account for their
+	// capacity and topology.
+	if (cpus.weighted > 0 || cpus.cores > 0) && cpus.limit < procs {
+		procs = cpus.limit
+		r.GOMAXPROCS, r.Status = procs, cpus.status
+	}
+	if cfg.Max > 0 && procs > cfg.Max {
+		r.GOMAXPROCS, r.Status = cfg.Max, CPUQuotaMaxUsed
+	}
+	if r.Status != CPUQuotaUndefined && cfg.Min > r.GOMAXPROCS {
+		r.GOMAXPROCS, r.Status = cfg.Min, CPUQuotaMinUsed
+	}
+	return r
+}
+
+// reservation returns the CPUs of quota that cfg reserves, which are never
+// more than quota.
+func reservation(cfg Config, quota float64) float64 {
+	var reserved float64
+	if cfg.ReserveCPUs > 0 {
+		reserved += cfg.ReserveCPUs
+	}
+	if cfg.ReserveFraction > 0 {
+		reserved += quota * cfg.ReserveFraction
+	}
+	if reserved > quota {
+		return quota
+	}
+	return reserved
+}
+
+// EffectiveCPUs returns the CPU capacity available to the calling process:
+// the smallest of its CPU quota, the number of CPUs in its cpuset, and the
+// number of CPUs it may run on. Unlike GOMAXPROCS, the result isn't rounded.
+//
+// If reading the cgroups fails, EffectiveCPUs returns the number of CPUs the
+// process may run on along with the error.
+func EffectiveCPUs() (float64, error) {
+	cpus := float64(_numCPU())
+	if affinity, err := _cpuAffinity(); err == nil && len(affinity) > 0 {
+		cpus = float64(len(affinity))
+	}
+
+	cgroups, err := _newQueryer(nil)
+	if err != nil {
+		return cpus, err
+	}
+	size, defined, err := cgroups.CPUSetSize()
+	if err != nil {
+		return cpus, err
+	}
+	if defined && float64(size) < cpus {
+		cpus = float64(size)
+	}
+	quota, defined, err := cgroups.CPUQuota()
+	if err != nil {
+		return cpus, err
+	}
+	if defined && quota < cpus {
+		cpus = quota
+	}
+	return cpus, nil
+}
+
+// PidsLimit returns the maximum number of tasks allowed in the cgroup of the
+// calling process, and whether such a limit is defined. If onInvalidLine is
+// set, lines that can't be parsed are passed to it and skipped, as with
+// Config.OnInvalidLine.
+func PidsLimit(onInvalidLine func(error)) (int, bool, error) {
+	cgroups, err := _newQueryer(onInvalidLine)
+	if err != nil {
+		return -1, false, err
+	}
+	return cgroups.PidsMax()
+}
+
+// CGroupsSource returns a QuotaSource that reads the CPU quota from the
+// cgroups of the calling process, using cgroups v2 if available and v1
+// otherwise.
+func CGroupsSource() QuotaSource {
+	return queryerSource(func() (queryer, error) { return _newQueryer(nil) })
+}
+
+// CGroupsV1Source returns a QuotaSource that reads the CPU quota from the
+// cgroups v1 CPU controller of the calling process.
+func CGroupsV1Source() QuotaSource {
+	return queryerSource(func() (queryer, error) { return _newCGroupsV1(nil) })
+}
+
+// CGroupsV2Source returns a QuotaSource that reads the CPU quota from the
+// cgroups v2 hierarchy of the calling process. The quota is undefined if the
+// system doesn't use cgroups v2.
+func CGroupsV2Source() QuotaSource {
+	return queryerSource(func() (queryer, error) { return _newCGroupsV2(nil) })
+}
+
+// queryer provides the cgroup parameters of the calling process.
+type queryer interface {
+	CPUQuota() (float64, bool, error)
+	CPURequest() (float64, bool, error)
+	PidsMax() (int, bool, error)
+	CPUSetSize() (int, bool, error)
+	MemoryLimit() (int64, bool, error)
+	CPUPeriod() (int, bool, error)
+	// Version is 1 or 2 for cgroups v1 or v2, and 0 outside of cgroups.
+	Version() int
+	Paths() map[string]string
+}
+
+// queryerSource is a QuotaSource that reads the cgroups afresh on each call,
+// so that it picks up changes to the cgroups of the process.
+type queryerSource func() (queryer, error)
+
+func (newQueryer queryerSource) CPUQuota() (float64, bool, error) {
+	cgroups, err := newQueryer()
+	if err != nil {
+		return -1, false, err
+	}
+	return cgroups.CPUQuota()
+}
+
+// undefinedQueryer is the queryer for processes outside of any cgroups.
+type undefinedQueryer struct{}
+
+func (undefinedQueryer) CPUQuota() (float64, bool, error)   { return -1, false, nil }
+func (undefinedQueryer) CPURequest() (float64, bool, error) { return -1, false, nil }
+func (undefinedQueryer) PidsMax() (int, bool, error)        { return -1, false, nil }
+func (undefinedQueryer) CPUSetSize() (int, bool, error)     { return -1, false, nil }
+func (undefinedQueryer) MemoryLimit() (int64, bool, error)  { return -1, false, nil }
+func (undefinedQueryer) CPUPeriod() (int, bool, error)      { return -1, false, nil }
+func (undefinedQueryer) Version() int                       { return 0 }
+func (undefinedQueryer) Paths() map[string]string           { return nil }
+
 // DefaultRoundFunc is the default function to convert CPU quota from float to int. It rounds the value down (floor).
 func DefaultRoundFunc(v float64) int {
 	return int(math.Floor(v))
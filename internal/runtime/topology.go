@@ -0,0 +1,76 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// _coreCPUsFiles are the files listing the hardware threads of the physical
+// core of a CPU, relative to its sysfs directory, by order of preference:
+// `core_cpus_list` replaced `thread_siblings_list` in Linux 5.7.
+var _coreCPUsFiles = []string{"topology/core_cpus_list", "topology/thread_siblings_list"}
+
+// physicalCores returns the number of physical cores that cpus belong to,
+// and whether it's defined. A core counts if any of its hardware threads is
+// in cpus. It's undefined unless the kernel reports the topology of every
+// CPU.
+func physicalCores(cpus []int) (int, bool, error) {
+	// Every hardware thread of a core lists the same threads, so the lists
+	// identify cores.
+	cores := make(map[string]struct{})
+	for _, cpu := range cpus {
+		threads, defined, err := coreCPUs(cpu)
+		if err != nil || !defined {
+			return -1, false, err
+		}
+		cores[threads] = struct{}{}
+	}
+	return len(cores), true, nil
+}
+
+// coreCPUs returns the list of hardware threads of the physical core of cpu,
+// and whether the kernel reports it.
+func coreCPUs(cpu int) (string, bool, error) {
+	dir := filepath.Join(_root, "/sys/devices/system/cpu", "cpu"+strconv.Itoa(cpu))
+	for _, name := range _coreCPUsFiles {
+		path := filepath.Join(dir, name)
+		text, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		threads := strings.TrimSpace(string(text))
+		if threads == "" {
+			return "", false, fmt.Errorf("empty list of core CPUs in %v", path)
+		}
+		return threads, true, nil
+	}
+	return "", false, nil
+}
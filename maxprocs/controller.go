@@ -0,0 +1,557 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrControllerBusy is returned by the methods of a Controller while
+	// another one runs, such as when Reset is called from another goroutine
+	// during Apply.
+	ErrControllerBusy = errors.New("maxprocs: controller is busy")
+
+	// ErrControllerClosed is returned by the methods of a Controller after
+	// Close.
+	ErrControllerClosed = errors.New("maxprocs: controller is closed")
+)
+
+// _owners lists the Controllers that own GOMAXPROCS, most recent last. Only
+// the most recent one changes GOMAXPROCS.
+var _owners struct {
+	sync.Mutex
+	stack []*Controller
+}
+
+// A Controller manages GOMAXPROCS for the options it was created with. It
+// takes ownership of GOMAXPROCS when applied, and gives it up when reset, or
+// closed.
+//
+// Resetting a Controller restores GOMAXPROCS, and the max threads with the
+// ThreadLimit option, to their values from before it took ownership, however
+// often it was applied. If several Controllers own GOMAXPROCS, for example
+// because several components call Set, the most recent one is in charge,
+// and the others stop re-evaluating the CPU quota. They can be reset in any
+// order: resetting a Controller that isn't the most recent one leaves
+// GOMAXPROCS unchanged, and hands the values to restore to the Controller
+// that took over from it.
+//
+// The methods of a Controller are safe for concurrent use, but return
+// ErrControllerBusy rather than wait for each other. The goroutines it starts
+// in the background, such as for ReevaluateOnSignal, wait for them instead,
+// so Reset and Close always restore GOMAXPROCS unless another method runs.
+type Controller struct {
+	cfg *config
+	// setCaller is the call site of Set that created the Controller, or 0.
+	setCaller uintptr
+
+	mu       sync.Mutex
+	busy     bool
+	closed   bool
+	decision Decision
+	// procs is the GOMAXPROCS the Controller last set, or 0 if it didn't,
+	// which DetectDrift checks against.
+	procs int
+	// overrides counts the calls to Override and to the methods that end
+	// an override, so that overrideTimer doesn't end a later one.
+	overrides     int
+	overrideTimer *time.Timer
+	signals       *signalHandler
+	drift         *driftChecker
+
+	// work is held while the Controller is applied, overridden or reset,
+	// by its methods or its background goroutines. It guards the following
+	// fields.
+	work    sync.Mutex
+	watcher *watcher
+	// goRuntime is how the Go runtime behaved when the Controller was last
+	// applied or overridden, which own checks.
+	goRuntime GoRuntime
+
+	// The following fields are guarded by _owners, since Reset hands them
+	// over to the Controller that took over.
+	owner bool
+	// prevProcs is GOMAXPROCS before the Controller took ownership, and
+	// procsChanged is set if it changed GOMAXPROCS since. prevDefault is set
+	// if the Go runtime was updating GOMAXPROCS then, in which case it's
+	// left to the runtime again rather than reset to prevProcs.
+	prevProcs    int
+	procsChanged bool
+	prevDefault  bool
+	// prevThreads and threadsChanged are the same for the max threads.
+	prevThreads    int
+	threadsChanged bool
+}
+
+// NewController returns a Controller that manages GOMAXPROCS with opts, as
+// Set does. It doesn't change anything until applied.
+func NewController(opts ...Option) *Controller {
+	return &Controller{cfg: newConfig(opts...)}
+}
+
+// Apply takes ownership of GOMAXPROCS if the Controller doesn't have it, and
+// sets GOMAXPROCS to match the Linux container CPU quota (if any), as Set
+// does. Applying the Controller again re-evaluates the CPU quota.
+func (c *Controller) Apply() error {
+	if err := c.begin(); err != nil {
+		return err
+	}
+	defer c.end()
+	c.work.Lock()
+	defer c.work.Unlock()
+	return c.apply(c.applyConfig())
+}
+
+// apply applies the Controller with cfg, a copy of its config from
+// applyConfig, which the watcher keeps, since a re-evaluation may still be
+// reading the previous one.
+func (c *Controller) apply(cfg *config) error {
+	c.stopWatching()
+	c.stopOverride()
+	c.own()
+
+	var d Decision
+	d.GoRuntime = cfg.goRuntime
+	applied, err := setMaxProcs(cfg, &d, nil)
+	d.GOMAXPROCS = currentMaxProcs()
+	if applied {
+		c.changedProcs()
+	}
+	if err == nil && cfg.threadLimit {
+		var prev int
+		prev, applied, err = setMaxThreads(cfg, &d)
+		if applied {
+			c.changedThreads(prev)
+		}
+	}
+	if interval := cfg.watchIntervalAfter(d); err == nil && interval > 0 {
+		c.watcher = newWatcher(cfg, d, c.inCharge, c.reportDecision, c.queueProcs)
+		c.watcher.start(interval)
+	}
+	if err == nil {
+		c.startWorkers(cfg.signal != nil)
+	}
+	if err != nil {
+		c.disownUnchanged()
+	}
+	c.setDecision(d)
+	if cfg.report != nil {
+		*cfg.report = d
+	}
+	return err
+}
+
+// Override takes ownership of GOMAXPROCS like Apply, but sets it to procs
+// rather than from the CPU quota, and stops re-evaluating the CPU quota. If d
+// is positive, the Controller is applied again after d, unless it's applied,
+// overridden, reset or closed before. Otherwise, the override lasts until
+// then. Resetting the Controller restores GOMAXPROCS as after Apply.
+func (c *Controller) Override(procs int, d time.Duration) error {
+	if procs < 1 {
+		return fmt.Errorf("maxprocs: invalid GOMAXPROCS override %v: must be at least 1", procs)
+	}
+	if err := c.begin(); err != nil {
+		return err
+	}
+	defer c.end()
+	c.work.Lock()
+	defer c.work.Unlock()
+
+	cfg := c.applyConfig()
+	c.stopWatching()
+	c.stopOverride()
+	c.own()
+
+	if d > 0 {
+		cfg.log("maxprocs: Overriding GOMAXPROCS=%v for %v", procs, d)
+	} else {
+		cfg.log("maxprocs: Overriding GOMAXPROCS=%v", procs)
+	}
+	cfg.setProcs(procs)
+	c.changedProcs()
+	c.setDecision(Decision{Status: Overridden, GOMAXPROCS: currentMaxProcs(), GoRuntime: cfg.goRuntime})
+	c.startWorkers(false)
+
+	if d > 0 {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		n := c.overrides
+		c.overrideTimer = time.AfterFunc(d, func() { c.endOverride(n) })
+	}
+	return nil
+}
+
+// Decision returns what the Controller did when it was last applied or
+// overridden, or re-evaluated the CPU quota with the Watch option.
+func (c *Controller) Decision() Decision {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.decision
+}
+
+// Facts detects the CPU and memory limits of the process, using the quota
+// source and the Lenient and Timeout options of the Controller.
+func (c *Controller) Facts() (Facts, error) {
+	cfg := c.cfg
+	var (
+		facts Facts
+		err   error
+	)
+	if !runWithTimeout(cfg.timeout, func() {
+		facts, err = cfg.detectFacts(cfg.source, cfg.invalidLineFunc(&Decision{}))
+	}) {
+		return Facts{}, ErrTimeout
+	}
+	return facts, err
+}
+
+// Reset gives up ownership of GOMAXPROCS, restoring the values from before
+// the Controller took it, unless another Controller took over since. The
+// Controller can be applied again afterwards.
+func (c *Controller) Reset() error {
+	if err := c.begin(); err != nil {
+		return err
+	}
+	defer c.end()
+	c.stopWorkers()
+	c.work.Lock()
+	defer c.work.Unlock()
+
+	c.reset()
+	return nil
+}
+
+// Close resets the Controller, after which it can't be used anymore. Closing
+// it again has no effect.
+func (c *Controller) Close() error {
+	if err := c.begin(); err != nil {
+		if errors.Is(err, ErrControllerClosed) {
+			return nil
+		}
+		return err
+	}
+	defer c.end()
+	c.stopWorkers()
+	c.work.Lock()
+	defer c.work.Unlock()
+
+	c.reset()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *Controller) begin() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrControllerClosed
+	}
+	if c.busy {
+		return ErrControllerBusy
+	}
+	c.busy = true
+	return nil
+}
+
+// end lets the other methods run again, and delivers the GOMAXPROCS changes
+// queued by the method that ends to OnChange subscribers, which may call
+// them.
+func (c *Controller) end() {
+	c.mu.Lock()
+	c.busy = false
+	c.mu.Unlock()
+	_changes.deliver()
+}
+
+func (c *Controller) reset() {
+	c.stopWatching()
+	// A background goroutine may have started the workers again since
+	// Reset or Close stopped them.
+	c.stopWorkers()
+
+	_owners.Lock()
+	owner, inCharge := c.owner, c.inChargeLocked()
+	prevProcs, procsChanged, prevDefault := c.prevProcs, c.procsChanged, c.prevDefault
+	prevThreads, threadsChanged := c.prevThreads, c.threadsChanged
+	c.disownLocked()
+	_owners.Unlock()
+
+	cfg := c.cfg
+	switch {
+	case !owner:
+		return
+	case !inCharge:
+		cfg.log("maxprocs: Leaving GOMAXPROCS=%v to the controller that took over", currentMaxProcs())
+		return
+	}
+
+	if threadsChanged {
+		cfg.log("maxprocs: Resetting max threads to %v", prevThreads)
+		cfg.setMaxThreads(prevThreads)
+	}
+	if procsChanged {
+		cfg.restoreMaxProcs(prevProcs, prevDefault)
+	} else {
+		cfg.log("maxprocs: No GOMAXPROCS change to reset")
+	}
+}
+
+// applyConfig returns a copy of the config of the Controller for applying or
+// overriding it, with the current behavior of the Go runtime.
+func (c *Controller) applyConfig() *config {
+	cfg := *c.cfg
+	cfg.goRuntime = cfg.detectGoRuntime()
+	cfg.savedProcs = c.savedProcs
+	c.goRuntime = cfg.goRuntime
+	return &cfg
+}
+
+// background runs f for a background goroutine of the Controller, once its
+// methods and its other goroutines are done. Like the methods, it delivers
+// the GOMAXPROCS changes queued by f once the others can run again, since
+// OnChange subscribers may call the methods of the Controller.
+func (c *Controller) background(f func()) {
+	defer _changes.deliver()
+	c.work.Lock()
+	defer c.work.Unlock()
+	f()
+}
+
+// startWorkers starts the drift checker with DetectDrift, and the signal
+// handler with ReevaluateOnSignal if signals is set, unless they run
+// already.
+func (c *Controller) startWorkers(signals bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if signals && c.signals == nil {
+		c.signals = c.handleSignals()
+	}
+	if c.cfg.driftInterval > 0 && c.drift == nil {
+		c.drift = c.checkDrift()
+	}
+}
+
+// stopWorkers stops the signal handler, the drift checker and the override
+// timer, if any. None of them changes anything once it returns, unless the
+// Controller is applied again.
+func (c *Controller) stopWorkers() {
+	c.stopOverride()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.signals != nil {
+		c.signals.close()
+		c.signals = nil
+	}
+	if c.drift != nil {
+		c.drift.close()
+		c.drift = nil
+	}
+}
+
+// stopWatching stops the watcher, if any.
+func (c *Controller) stopWatching() {
+	if c.watcher == nil {
+		return
+	}
+	c.watcher.close()
+	c.watcher = nil
+}
+
+// stopOverride keeps the override timer, if any, from applying the
+// Controller.
+func (c *Controller) stopOverride() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.overrides++
+	if c.overrideTimer != nil {
+		c.overrideTimer.Stop()
+		c.overrideTimer = nil
+	}
+}
+
+// endOverride applies the Controller when the override timer fires, unless
+// the override numbered n was ended since.
+func (c *Controller) endOverride(n int) {
+	c.background(func() {
+		c.mu.Lock()
+		current := c.overrides == n
+		c.mu.Unlock()
+		if !current {
+			return
+		}
+		c.cfg.log("maxprocs: Ending GOMAXPROCS override")
+		if err := c.apply(c.applyConfig()); err != nil {
+			c.cfg.log("maxprocs: Failed to re-evaluate GOMAXPROCS: %v", err)
+		}
+	})
+}
+
+// setDecision records d, and the GOMAXPROCS it set, if any.
+func (c *Controller) setDecision(d Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decision, c.procs = d, 0
+	if d.procsSet() {
+		c.procs = d.GOMAXPROCS
+	}
+}
+
+// reportDecision records d from a re-evaluation of the watcher, which sets
+// GOMAXPROCS with queueProcs.
+func (c *Controller) reportDecision(d Decision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decision = d
+}
+
+// queueProcs queues a change of GOMAXPROCS to procs for the watcher, and
+// records procs for DetectDrift, so that it doesn't mistake the change for
+// a drift.
+func (c *Controller) queueProcs(procs int) {
+	c.mu.Lock()
+	c.procs = procs
+	_changes.queue(procs)
+	c.mu.Unlock()
+	c.changedProcs()
+}
+
+// own makes the Controller the one in charge of GOMAXPROCS.
+func (c *Controller) own() {
+	_owners.Lock()
+	defer _owners.Unlock()
+
+	if c.inChargeLocked() {
+		return
+	}
+	// Hand over what the Controller changed to the one that took over from
+	// it, which restores it instead.
+	c.disownLocked()
+
+	// A Controller created by the same call site of Set as the one in charge
+	// replaces it rather than nesting, so that calling Set repeatedly without
+	// undoing it doesn't grow the stack.
+	others := len(_owners.stack)
+	var replaced *Controller
+	if others > 0 && c.setCaller != 0 && _owners.stack[others-1].setCaller == c.setCaller {
+		replaced = _owners.stack[others-1]
+		others--
+	}
+
+	c.owner = true
+	c.prevProcs, c.procsChanged = currentMaxProcs(), false
+	// GOMAXPROCS is only left to the Go runtime again if nothing set it
+	// before: no other Controller owns it, and it has its value from when
+	// the process started.
+	c.prevDefault = c.goRuntime.Updates && others == 0 && c.prevProcs == _startProcs
+	c.prevThreads, c.threadsChanged = 0, false
+	_owners.stack = append(_owners.stack, c)
+	if replaced != nil {
+		replaced.disownLocked()
+	}
+}
+
+// disownLocked gives up ownership, handing over the values to restore to the
+// Controller that took over, if any.
+func (c *Controller) disownLocked() {
+	if !c.owner {
+		return
+	}
+	c.owner = false
+
+	stack := _owners.stack
+	for i, other := range stack {
+		if other != c {
+			continue
+		}
+		if i+1 < len(stack) {
+			next := stack[i+1]
+			if c.procsChanged {
+				next.prevProcs, next.procsChanged, next.prevDefault = c.prevProcs, true, c.prevDefault
+			}
+			if c.threadsChanged {
+				next.prevThreads, next.threadsChanged = c.prevThreads, true
+			}
+		}
+		_owners.stack = append(stack[:i:i], stack[i+1:]...)
+		return
+	}
+}
+
+// disownUnchanged gives up ownership if the Controller didn't change
+// anything, so that failing to apply it has no lasting effect.
+func (c *Controller) disownUnchanged() {
+	_owners.Lock()
+	defer _owners.Unlock()
+	if !c.procsChanged && !c.threadsChanged {
+		c.disownLocked()
+	}
+}
+
+// savedProcs returns the GOMAXPROCS from before the Controller took
+// ownership, or 0 if the Go runtime set it, if the Controller is in charge
+// and changed it since.
+func (c *Controller) savedProcs() (int, bool) {
+	_owners.Lock()
+	defer _owners.Unlock()
+	switch {
+	case !c.procsChanged || !c.inChargeLocked():
+		return 0, false
+	case c.prevDefault:
+		return 0, true
+	default:
+		return c.prevProcs, true
+	}
+}
+
+func (c *Controller) changedProcs() {
+	_owners.Lock()
+	defer _owners.Unlock()
+	c.procsChanged = true
+}
+
+func (c *Controller) changedThreads(prev int) {
+	_owners.Lock()
+	defer _owners.Unlock()
+	// Keep the max threads from before the Controller took ownership if it
+	// was applied before.
+	if !c.threadsChanged {
+		c.prevThreads, c.threadsChanged = prev, true
+	}
+}
+
+// inCharge reports whether the Controller is the most recent owner of
+// GOMAXPROCS.
+func (c *Controller) inCharge() bool {
+	_owners.Lock()
+	defer _owners.Unlock()
+	return c.inChargeLocked()
+}
+
+func (c *Controller) inChargeLocked() bool {
+	stack := _owners.stack
+	return len(stack) > 0 && stack[len(stack)-1] == c
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+func TestController(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+		require.Empty(t, _owners.stack, "controllers still own GOMAXPROCS")
+	}()
+
+	t.Run("ApplyTwice", func(t *testing.T) {
+		quota := &stubQuota{quota: 3}
+		c := NewController(quota.option())
+		defer c.Close()
+
+		require.NoError(t, c.Apply(), "Apply failed")
+		assert.Equal(t, 3, currentMaxProcs())
+
+		quota.Set(4, nil)
+		require.NoError(t, c.Apply(), "Apply failed")
+		assert.Equal(t, 4, currentMaxProcs(), "should re-evaluate the CPU quota")
+
+		require.NoError(t, c.Reset(), "Reset failed")
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the first Apply")
+
+		require.NoError(t, c.Apply(), "Apply after Reset failed")
+		assert.Equal(t, 4, currentMaxProcs())
+	})
+
+	t.Run("ResetInOrder", func(t *testing.T) {
+		a := NewController((&stubQuota{quota: 2}).option())
+		b := NewController((&stubQuota{quota: 3}).option())
+		require.NoError(t, a.Apply(), "Apply failed")
+		require.NoError(t, b.Apply(), "Apply failed")
+		assert.Equal(t, 3, currentMaxProcs())
+
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, 2, currentMaxProcs(), "should restore the value set by the previous controller")
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, prev, currentMaxProcs())
+	})
+
+	t.Run("ResetOutOfOrder", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		a := NewController(logOpt, (&stubQuota{quota: 2}).option())
+		b := NewController((&stubQuota{quota: 3}).option())
+		require.NoError(t, a.Apply(), "Apply failed")
+		require.NoError(t, b.Apply(), "Apply failed")
+
+		buf.Reset()
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't reset while another controller is in charge")
+		assert.Equal(t, "maxprocs: Leaving GOMAXPROCS=3 to the controller that took over", buf.String())
+
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the first controller")
+	})
+
+	t.Run("UndoSetTwice", func(t *testing.T) {
+		undoA, err := Set((&stubQuota{quota: 2}).option())
+		require.NoError(t, err, "Set failed")
+		undoB, err := Set((&stubQuota{quota: 3}).option())
+		require.NoError(t, err, "Set failed")
+
+		undoA()
+		undoB()
+		assert.Equal(t, prev, currentMaxProcs(), "undos in the wrong order should restore the original value")
+	})
+
+	t.Run("SetRepeatedly", func(t *testing.T) {
+		var undos []func()
+		for i := 0; i < 100; i++ {
+			undo, err := Set((&stubQuota{quota: float64(prev + 1 + i%2)}).option())
+			require.NoError(t, err, "Set failed")
+			undos = append(undos, undo)
+		}
+		assert.Len(t, _owners.stack, 1, "calls from the same place shouldn't nest")
+
+		undos[0]()
+		assert.Equal(t, prev+2, currentMaxProcs(), "undoing a replaced call should have no effect")
+		undos[len(undos)-1]()
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the first call")
+	})
+
+	t.Run("ReapplyOlder", func(t *testing.T) {
+		a := NewController((&stubQuota{quota: 2}).option())
+		b := NewController((&stubQuota{quota: 3}).option())
+		require.NoError(t, a.Apply(), "Apply failed")
+		require.NoError(t, b.Apply(), "Apply failed")
+		require.NoError(t, a.Apply(), "Apply failed")
+		assert.Equal(t, 2, currentMaxProcs(), "should take over again")
+
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, 3, currentMaxProcs(), "should restore the value set by the other controller")
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, prev, currentMaxProcs())
+	})
+
+	t.Run("ThreadLimitHandedOver", func(t *testing.T) {
+		var calls []int
+		threadsOpt := stubThreads(5000, true, nil, func(n int) int {
+			calls = append(calls, n)
+			return 10000
+		})
+		a := NewController((&stubQuota{quota: 2}).option(), threadsOpt, ThreadLimit(0))
+		b := NewController((&stubQuota{quota: 3}).option(), threadsOpt)
+		require.NoError(t, a.Apply(), "Apply failed")
+		require.NoError(t, b.Apply(), "Apply failed")
+
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, []int{5000}, calls, "shouldn't reset while another controller is in charge")
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, []int{5000, 10000}, calls, "should restore the max threads from before the first controller")
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		stubs := gostub.StubFunc(&_effectiveCPUs, 2.0, nil)
+		defer stubs.Reset()
+		defer _watchers.Wait()
+
+		quota := &stubQuota{quota: 2}
+		a := NewController(quota.option(), Watch(time.Millisecond))
+		b := NewController((&stubQuota{quota: 3}).option())
+		require.NoError(t, a.Apply(), "Apply failed")
+		defer a.Close()
+		require.NoError(t, b.Apply(), "Apply failed")
+
+		quota.Set(4, nil)
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't watch while another controller is in charge")
+
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Eventually(t, func() bool { return currentMaxProcs() == 4 }, time.Second, time.Millisecond,
+			"should watch again once in charge")
+	})
+
+	t.Run("ApplyFailed", func(t *testing.T) {
+		c := NewController((&stubQuota{err: errors.New("failed")}).option())
+		require.Error(t, c.Apply(), "Apply should have failed")
+		assert.False(t, c.inCharge(), "shouldn't keep ownership without changes")
+		require.NoError(t, c.Close(), "Close failed")
+	})
+
+	t.Run("Busy", func(t *testing.T) {
+		entered, released := make(chan struct{}), make(chan struct{})
+		c := NewController(optionFunc(func(cfg *config) {
+			cfg.procs = func(iruntime.Config) (iruntime.Result, error) {
+				close(entered)
+				<-released
+				return iruntime.Result{GOMAXPROCS: -1, Status: iruntime.CPUQuotaUndefined, Quota: -1}, nil
+			}
+		}))
+		defer c.Close()
+
+		errs := make(chan error, 1)
+		go func() { errs <- c.Apply() }()
+		<-entered
+		assert.Equal(t, ErrControllerBusy, c.Reset(), "Reset should fail while Apply runs")
+		close(released)
+		require.NoError(t, <-errs, "Apply failed")
+	})
+
+	t.Run("Decision", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 3}).option())
+		defer c.Close()
+		assert.Equal(t, Decision{}, c.Decision(), "should be empty before Apply")
+
+		require.NoError(t, c.Apply(), "Apply failed")
+		d := c.Decision()
+		assert.Equal(t, QuotaUsed, d.Status)
+		assert.Equal(t, 3, d.GOMAXPROCS)
+		assert.Equal(t, 3.0, d.Quota)
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		c := NewController(logOpt, (&stubQuota{quota: 3}).option())
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+
+		buf.Reset()
+		require.NoError(t, c.Override(5, 0), "Override failed")
+		assert.Equal(t, 5, currentMaxProcs())
+		assert.Equal(t, Overridden, c.Decision().Status)
+		assert.Equal(t, 5, c.Decision().GOMAXPROCS)
+		assert.Equal(t, "maxprocs: Overriding GOMAXPROCS=5", buf.String())
+
+		require.NoError(t, c.Apply(), "Apply failed")
+		assert.Equal(t, 3, currentMaxProcs(), "Apply should end the override")
+
+		require.NoError(t, c.Override(5, 0), "Override failed")
+		require.NoError(t, c.Reset(), "Reset failed")
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the controller")
+
+		assert.Error(t, c.Override(0, 0), "should reject non-positive values")
+	})
+
+	t.Run("OverrideExpires", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 3}).option())
+		defer c.Close()
+
+		require.NoError(t, c.Override(5, time.Millisecond), "Override failed")
+		assert.Eventually(t, func() bool { return c.Decision().Status == QuotaUsed }, time.Second, time.Millisecond,
+			"should apply the controller once the override expires")
+		assert.Equal(t, 3, currentMaxProcs())
+	})
+
+	t.Run("OverrideEnded", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 3}).option())
+		defer c.Close()
+
+		require.NoError(t, c.Override(5, 5*time.Millisecond), "Override failed")
+		require.NoError(t, c.Override(4, 0), "Override failed")
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, 4, currentMaxProcs(), "a later override shouldn't expire")
+
+		require.NoError(t, c.Override(5, 5*time.Millisecond), "Override failed")
+		require.NoError(t, c.Reset(), "Reset failed")
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, prev, currentMaxProcs(), "the controller shouldn't be applied after Reset")
+	})
+
+	t.Run("Facts", func(t *testing.T) {
+		c := NewController(optionFunc(func(cfg *config) {
+			cfg.detectFacts = func(source iruntime.QuotaSource, _ func(error)) (iruntime.Facts, error) {
+				assert.NotNil(t, source, "should use the quota source")
+				return iruntime.Facts{Quota: 2, HostCPUs: 8}, nil
+			}
+		}), Source(QuotaSourceFunc(func() (float64, bool, error) { return 2, true, nil })))
+
+		facts, err := c.Facts()
+		require.NoError(t, err)
+		assert.Equal(t, Facts{Quota: 2, HostCPUs: 8}, facts)
+	})
+
+	t.Run("ApplyWhileWatching", func(t *testing.T) {
+		// Run with -race: re-evaluations that are still running when Apply
+		// stops the watcher mustn't share state with the next Apply.
+		c := NewController((&stubQuota{quota: 2}).option(), Watch(time.Microsecond))
+		defer c.Close()
+
+		deadline := time.Now().Add(50 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			require.NoError(t, c.Apply(), "Apply failed")
+			require.NoError(t, c.Override(3, 0), "Override failed")
+		}
+		require.NoError(t, c.Reset(), "Reset failed")
+		// Don't leave re-evaluations running into other tests.
+		_watchers.Wait()
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 3}).option())
+		require.NoError(t, c.Apply(), "Apply failed")
+		require.NoError(t, c.Close(), "Close failed")
+		assert.NoError(t, c.Close(), "closing again should have no effect")
+		assert.Equal(t, ErrControllerClosed, c.Apply())
+		assert.Equal(t, ErrControllerClosed, c.Reset())
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"math"
+	"sync"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+var (
+	_effectiveCPUs = iruntime.EffectiveCPUs
+
+	// _cpus caches the result of _effectiveCPUs, read when the hooks of
+	// maxprocstest were at version.
+	_cpus struct {
+		sync.Mutex
+		read    bool
+		value   float64
+		version int64
+	}
+)
+
+// EffectiveCPUs returns the CPU capacity available to the process, in CPUs:
+// the smallest of its Linux container CPU quota, the number of CPUs in its
+// cpuset, and the number of CPUs it may run on. Unlike runtime.NumCPU, it
+// accounts for the quota, and unlike GOMAXPROCS, it doesn't depend on what
+// the application chose. Libraries can use it to size worker pools,
+// connection pools and sharded data structures.
+//
+// The capacity is read on the first call and cached. RefreshEffectiveCPUs
+// reads it again, and so does every re-evaluation with the Watch option. If
+// the cgroups of the process can't be read, EffectiveCPUs falls back to the
+// number of CPUs the process may run on.
+func EffectiveCPUs() float64 {
+	_cpus.Lock()
+	defer _cpus.Unlock()
+
+	if version := iruntime.HooksVersion(); !_cpus.read || _cpus.version != version {
+		_cpus.value, _ = _effectiveCPUs()
+		_cpus.read, _cpus.version = true, version
+	}
+	return _cpus.value
+}
+
+// EffectiveCPUCount returns EffectiveCPUs rounded down, and at least 1.
+func EffectiveCPUCount() int {
+	return cpuCount(EffectiveCPUs())
+}
+
+// RefreshEffectiveCPUs reads the CPU capacity available to the process
+// again, for example after the container was resized, and returns the new
+// value of EffectiveCPUs.
+func RefreshEffectiveCPUs() float64 {
+	version := iruntime.HooksVersion()
+	v, _ := _effectiveCPUs()
+
+	_cpus.Lock()
+	defer _cpus.Unlock()
+	_cpus.value, _cpus.read, _cpus.version = v, true, version
+	return v
+}
+
+func cpuCount(cpus float64) int {
+	if n := int(math.Floor(cpus)); n > 1 {
+		return n
+	}
+	return 1
+}
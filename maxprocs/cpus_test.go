@@ -0,0 +1,57 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveCPUs(t *testing.T) {
+	var reads int
+	value := 2.5
+	stubs := gostub.Stub(&_effectiveCPUs, func() (float64, error) {
+		reads++
+		return value, nil
+	})
+	defer stubs.Reset()
+	defer func() { _cpus.read = false }()
+	_cpus.read = false
+
+	assert.Equal(t, 2.5, EffectiveCPUs())
+	assert.Equal(t, 2, EffectiveCPUCount())
+	assert.Equal(t, 1, reads, "should cache the capacity")
+
+	value = 0.5
+	assert.Equal(t, 2.5, EffectiveCPUs(), "should use the cached capacity")
+	assert.Equal(t, 0.5, RefreshEffectiveCPUs())
+	assert.Equal(t, 0.5, EffectiveCPUs(), "should cache the refreshed capacity")
+	assert.Equal(t, 1, EffectiveCPUCount(), "should be at least 1")
+	assert.Equal(t, 2, reads)
+
+	stubs.Stub(&_effectiveCPUs, func() (float64, error) {
+		return 4, errors.New("great sadness")
+	})
+	assert.Equal(t, 4.0, RefreshEffectiveCPUs(), "should use the fallback on errors")
+}
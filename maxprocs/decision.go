@@ -0,0 +1,160 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"fmt"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+// Status describes how Set determined GOMAXPROCS.
+type Status int
+
+const (
+	// QuotaUndefined means that GOMAXPROCS was left unchanged because no CPU
+	// quota is defined.
+	QuotaUndefined Status = iota
+	// QuotaUsed means that GOMAXPROCS was determined from the CPU quota.
+	QuotaUsed
+	// MinUsed means that the CPU quota was smaller than the minimum allowed
+	// GOMAXPROCS, which was used instead.
+	MinUsed
+	// RequestUsed means that GOMAXPROCS was estimated from the CPU request
+	// because no CPU quota is defined. See CPURequest.
+	RequestUsed
+	// AffinityUsed means that GOMAXPROCS was limited to the number of CPUs
+	// the process may run on, or their capacity. See CapacityWeighted.
+	AffinityUsed
+	// EnvUsed means that GOMAXPROCS was left as set by the GOMAXPROCS
+	// environment variable.
+	EnvUsed
+	// TimedOut means that GOMAXPROCS was left unchanged because reading the
+	// CPU quota took too long. See Timeout.
+	TimedOut
+	// RuntimeUsed means that GOMAXPROCS was left to the Go runtime, which
+	// sets it from the CPU limit. See Runtime.
+	RuntimeUsed
+	// MaxUsed means that the value derived from the CPU quota, or the
+	// number of CPUs if no quota is defined, was larger than the maximum
+	// allowed GOMAXPROCS, which was used instead. See PolicyRule.Max.
+	MaxUsed
+	// Overridden means that GOMAXPROCS was set by Controller.Override.
+	Overridden
+	// CoresUsed means that GOMAXPROCS was limited to the number of physical
+	// cores the process may run on. See PhysicalCores.
+	CoresUsed
+	// QuotaRemoved means that the CPU quota GOMAXPROCS was set from was
+	// removed when re-evaluated, so GOMAXPROCS was reset to its value from
+	// before, or left to the Go runtime again.
+	QuotaRemoved
+)
+
+var _statusNames = map[Status]string{
+	QuotaUndefined: "quota undefined",
+	QuotaUsed:      "quota used",
+	MinUsed:        "min used",
+	RequestUsed:    "request used",
+	AffinityUsed:   "affinity used",
+	EnvUsed:        "env used",
+	TimedOut:       "timed out",
+	RuntimeUsed:    "runtime used",
+	MaxUsed:        "max used",
+	Overridden:     "overridden",
+	CoresUsed:      "cores used",
+	QuotaRemoved:   "quota removed",
+}
+
+func (s Status) String() string {
+	if name, ok := _statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+func statusFromCPUQuota(status iruntime.CPUQuotaStatus) Status {
+	switch status {
+	case iruntime.CPUQuotaUsed:
+		return QuotaUsed
+	case iruntime.CPUQuotaMinUsed:
+		return MinUsed
+	case iruntime.CPUQuotaRequestUsed:
+		return RequestUsed
+	case iruntime.CPUQuotaAffinityUsed:
+		return AffinityUsed
+	case iruntime.CPUQuotaMaxUsed:
+		return MaxUsed
+	case iruntime.CPUQuotaCoresUsed:
+		return CoresUsed
+	default:
+		return QuotaUndefined
+	}
+}
+
+// Decision describes what Set did.
+type Decision struct {
+	// Status describes how GOMAXPROCS was determined.
+	Status Status
+	// GOMAXPROCS is the value of GOMAXPROCS after Set returned.
+	GOMAXPROCS int
+	// Quota is the CPU quota, or the estimate from the CPU request, in CPUs
+	// before rounding. It's zero if neither is defined.
+	Quota float64
+	// Reserved is the part of Quota reserved by ReserveCPUs and
+	// ReserveFraction, which GOMAXPROCS doesn't account for.
+	Reserved float64
+	// CPUs is the number of CPUs the process may run on, or 0 if unknown,
+	// and WeightedCPUs their count weighted by capacity with the
+	// CapacityWeighted option, or 0 if unknown or not computed.
+	CPUs         int
+	WeightedCPUs float64
+	// PhysicalCores is the number of physical cores of these CPUs with the
+	// PhysicalCores option, or 0 if unknown or not counted.
+	PhysicalCores int
+	// Env is the outcome of the GOMAXPROCS environment variable, whose value
+	// is EnvValue.
+	Env      EnvOutcome
+	EnvValue string
+	// GoRuntime describes how the Go runtime sets GOMAXPROCS by itself.
+	GoRuntime GoRuntime
+	// Rounding is the name of the RoundingStrategy that rounded the CPU
+	// quota, if selected with the Rounding option or a Policy rule. It's
+	// empty with the default rounding, which is floor, and with
+	// RoundQuotaFunc.
+	Rounding string
+	// Rules lists the names of the Policy rules that applied. See
+	// PolicyFile.
+	Rules []string
+	// Warnings lists the problems Set worked around, such as lines skipped
+	// with the Lenient option.
+	Warnings []string
+}
+
+// Facts describes the CPU and memory limits of the process, as detected by
+// Controller.Facts. Policy rules match against them.
+type Facts = iruntime.Facts
+
+// Report makes Set store a description of what it did in d, even if it fails.
+func Report(d *Decision) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.report = d
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// _drifts counts the drifts found by DetectDrift.
+var _drifts int64
+
+// DetectDrift makes Set check every interval that GOMAXPROCS still has the
+// value it set, and log when something else changed it, such as a library
+// calling runtime.GOMAXPROCS. DriftCount counts these changes, and
+// CorrectDrift sets GOMAXPROCS back.
+//
+// GOMAXPROCS is only checked if Set changed it: not if it left it to the
+// GOMAXPROCS environment variable or the Go runtime, or unchanged for lack
+// of a CPU quota. A Controller checks against the value it was last applied
+// or overridden with, or changed to by Watch, while it's in charge of
+// GOMAXPROCS. The undo function stops checking, as resetting or closing a
+// Controller does. By default, or if interval isn't positive, Set doesn't
+// check.
+func DetectDrift(interval time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.driftInterval = interval
+	})
+}
+
+// CorrectDrift makes DetectDrift set GOMAXPROCS back to the value Set chose
+// when something else changes it.
+func CorrectDrift() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.correctDrift = true
+	})
+}
+
+// DriftCount returns how many times DetectDrift found that something else
+// changed GOMAXPROCS. Each value GOMAXPROCS drifts to is counted once, unless
+// corrected.
+func DriftCount() int64 {
+	return atomic.LoadInt64(&_drifts)
+}
+
+// driftChecker checks GOMAXPROCS for DetectDrift.
+type driftChecker struct {
+	c    *Controller
+	stop chan struct{}
+	// drifted is the value GOMAXPROCS last drifted to without being
+	// corrected, or 0. It's guarded by the Controller's mu.
+	drifted int
+}
+
+// checkDrift starts checking GOMAXPROCS every interval of the Controller's
+// config.
+func (c *Controller) checkDrift() *driftChecker {
+	dc := &driftChecker{c: c, stop: make(chan struct{})}
+	go dc.run(c.cfg.driftInterval)
+	return dc
+}
+
+// close stops checking GOMAXPROCS. An ongoing check doesn't change anything
+// once it returns.
+func (dc *driftChecker) close() {
+	close(dc.stop)
+}
+
+func (dc *driftChecker) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-dc.stop:
+			return
+		case <-ticker.C:
+			dc.check()
+		}
+	}
+}
+
+func (dc *driftChecker) check() {
+	dc.c.background(dc.checkLocked)
+}
+
+func (dc *driftChecker) checkLocked() {
+	c := dc.c
+	want, procs, drifted := dc.compare()
+	switch {
+	case !drifted:
+		return
+	case !c.cfg.correctDrift:
+		c.cfg.log("maxprocs: Leaving GOMAXPROCS=%v: drifted from %v", procs, want)
+		return
+	}
+	c.cfg.log("maxprocs: Restoring GOMAXPROCS=%v: drifted to %v", want, procs)
+	c.changedProcs()
+}
+
+// compare compares GOMAXPROCS with the value the Controller set, and
+// reports whether it drifted to a new value, queuing the correction with
+// CorrectDrift. The watcher can't change GOMAXPROCS meanwhile. It doesn't
+// compare if the drift checker was stopped, or another Controller is in
+// charge of GOMAXPROCS.
+func (dc *driftChecker) compare() (want, procs int, drifted bool) {
+	c := dc.c
+	if !c.inCharge() {
+		return 0, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.drift != dc {
+		return 0, 0, false
+	}
+	want, procs = c.procs, currentMaxProcs()
+	switch {
+	case want == 0 || procs == want:
+		dc.drifted = 0
+		return want, procs, false
+	case procs == dc.drifted:
+		return want, procs, false
+	}
+
+	atomic.AddInt64(&_drifts, 1)
+	if c.cfg.correctDrift {
+		_changes.queue(want)
+	} else {
+		dc.drifted = procs
+	}
+	return want, procs, true
+}
+
+// procsSet reports whether GOMAXPROCS was set to d.GOMAXPROCS.
+func (d Decision) procsSet() bool {
+	switch d.Status {
+	case QuotaUsed, MinUsed, RequestUsed, AffinityUsed, MaxUsed, Overridden, CoresUsed:
+		return true
+	default:
+		return false
+	}
+}
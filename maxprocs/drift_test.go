@@ -0,0 +1,146 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDrift(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	t.Run("Detect", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		c := NewController(logOpt, (&stubQuota{quota: 2}).option(), DetectDrift(time.Hour))
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+		require.NotNil(t, c.drift, "should check for drift")
+		count := DriftCount()
+
+		buf.Reset()
+		c.drift.check()
+		assert.Empty(t, buf.String(), "shouldn't log without drift")
+		assert.Equal(t, count, DriftCount())
+
+		runtime.GOMAXPROCS(5)
+		c.drift.check()
+		assert.Equal(t, "maxprocs: Leaving GOMAXPROCS=5: drifted from 2", buf.String())
+		assert.Equal(t, count+1, DriftCount())
+		assert.Equal(t, 5, currentMaxProcs(), "shouldn't correct drift by default")
+
+		buf.Reset()
+		c.drift.check()
+		assert.Empty(t, buf.String(), "should log each drift once")
+		assert.Equal(t, count+1, DriftCount(), "should count each drift once")
+
+		runtime.GOMAXPROCS(2)
+		c.drift.check()
+		runtime.GOMAXPROCS(5)
+		c.drift.check()
+		assert.Equal(t, "maxprocs: Leaving GOMAXPROCS=5: drifted from 2", buf.String())
+		assert.Equal(t, count+2, DriftCount(), "should count drifting again")
+
+		require.NoError(t, c.Reset(), "Reset failed")
+		assert.Nil(t, c.drift, "Reset should stop checking for drift")
+	})
+
+	t.Run("Correct", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		c := NewController(logOpt, (&stubQuota{quota: 2}).option(), DetectDrift(time.Hour), CorrectDrift())
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+		count := DriftCount()
+
+		buf.Reset()
+		runtime.GOMAXPROCS(5)
+		c.drift.check()
+		assert.Equal(t, "maxprocs: Restoring GOMAXPROCS=2: drifted to 5", buf.String())
+		assert.Equal(t, 2, currentMaxProcs(), "should correct drift")
+		assert.Equal(t, count+1, DriftCount())
+		history := History()
+		assert.Equal(t, Change{Time: history[len(history)-1].Time, Old: 5, New: 2}, history[len(history)-1])
+
+		runtime.GOMAXPROCS(5)
+		c.drift.check()
+		assert.Equal(t, 2, currentMaxProcs(), "should correct the same drift again")
+		assert.Equal(t, count+2, DriftCount())
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 2}).option(), DetectDrift(time.Hour), CorrectDrift())
+		defer c.Close()
+		require.NoError(t, c.Override(3, 0), "Override failed")
+		require.NotNil(t, c.drift, "should check for drift")
+
+		runtime.GOMAXPROCS(5)
+		c.drift.check()
+		assert.Equal(t, 3, currentMaxProcs(), "should correct drift from the override")
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		quota := &stubQuota{quota: 2}
+		c := NewController(quota.option(), Watch(time.Hour), DetectDrift(time.Hour), CorrectDrift())
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+
+		quota.Set(3, nil)
+		c.watcher.reevaluate()
+		c.drift.check()
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't mistake changes of the watcher for drift")
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		c := NewController((&stubQuota{}).option(), DetectDrift(time.Hour), CorrectDrift())
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+		count := DriftCount()
+
+		runtime.GOMAXPROCS(5)
+		defer runtime.GOMAXPROCS(prev)
+		c.drift.check()
+		assert.Equal(t, 5, currentMaxProcs(), "shouldn't check GOMAXPROCS the Controller didn't set")
+		assert.Equal(t, count, DriftCount())
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		c := NewController((&stubQuota{quota: 2}).option())
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+		assert.Nil(t, c.drift, "shouldn't check for drift by default")
+	})
+
+	t.Run("Ticker", func(t *testing.T) {
+		undo, err := Set((&stubQuota{quota: 2}).option(), DetectDrift(time.Millisecond), CorrectDrift())
+		require.NoError(t, err, "Set failed")
+		defer undo()
+
+		runtime.GOMAXPROCS(5)
+		assert.Eventually(t, func() bool { return currentMaxProcs() == 2 }, time.Second, time.Millisecond, "should check every interval")
+	})
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// EnvPolicy selects how Set treats the GOMAXPROCS environment variable, which
+// the Go runtime applies at startup.
+type EnvPolicy int
+
+const (
+	// EnvHonor leaves GOMAXPROCS unchanged whenever the variable is set, even
+	// if it's empty or invalid. This is the default.
+	EnvHonor EnvPolicy = iota
+	// EnvIgnore ignores the variable, and sets GOMAXPROCS as if it was
+	// unset.
+	EnvIgnore
+	// EnvValidate honors the variable if it's a positive integer, and
+	// otherwise sets GOMAXPROCS as if it was unset.
+	EnvValidate
+	// EnvClamp validates the variable like EnvValidate, but lowers
+	// GOMAXPROCS to the value derived from the CPU quota if the variable is
+	// larger.
+	EnvClamp
+)
+
+// EnvOverride selects how Set treats the GOMAXPROCS environment variable. By
+// default, Set uses EnvHonor.
+func EnvOverride(p EnvPolicy) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.envPolicy = p
+	})
+}
+
+// EnvOutcome describes how Set treated the GOMAXPROCS environment variable.
+type EnvOutcome int
+
+const (
+	// EnvUnset means the variable wasn't set.
+	EnvUnset EnvOutcome = iota
+	// EnvHonored means the variable was honored.
+	EnvHonored
+	// EnvIgnored means the variable was ignored because of the EnvIgnore
+	// policy.
+	EnvIgnored
+	// EnvInvalid means the variable was ignored because it isn't a positive
+	// integer.
+	EnvInvalid
+	// EnvClamped means GOMAXPROCS was lowered from the value of the variable
+	// to the value derived from the CPU quota.
+	EnvClamped
+)
+
+var _envOutcomeNames = map[EnvOutcome]string{
+	EnvUnset:   "unset",
+	EnvHonored: "honored",
+	EnvIgnored: "ignored",
+	EnvInvalid: "invalid",
+	EnvClamped: "clamped",
+}
+
+func (o EnvOutcome) String() string {
+	if name, ok := _envOutcomeNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("EnvOutcome(%d)", int(o))
+}
+
+// parseEnvMaxProcs parses the GOMAXPROCS environment variable, returning
+// false if it isn't a positive integer.
+func parseEnvMaxProcs(v string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n > 0
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/automaxprocs/internal/cgroups"
+)
+
+var (
+	// ErrInvalidFormat indicates that a line of `/proc/self/mountinfo`,
+	// `/proc/self/cgroup` or a cgroup file is malformed. Errors returned by Set
+	// match it with errors.Is.
+	ErrInvalidFormat = cgroups.ErrInvalidFormat
+
+	// ErrInvalidValue indicates that a cgroup file holds a value that isn't
+	// allowed, such as a zero CPU period in `cpu.max`.
+	ErrInvalidValue = cgroups.ErrInvalidValue
+
+	// ErrTimeout is wrapped in the *DetectionError returned by Set in strict
+	// mode if reading the CPU quota timed out. See Timeout.
+	ErrTimeout = errors.New("timed out")
+)
+
+// ParseError is returned, possibly wrapped, by Set if a `/proc` or cgroup
+// file can't be parsed. It records the path and line number of the offending
+// content, and wraps ErrInvalidFormat or ErrInvalidValue. Use errors.As to
+// retrieve it.
+type ParseError = cgroups.ParseError
+
+// PathNotExposedError is returned, possibly wrapped, by Set if the cgroup of
+// the process isn't visible from the mount point of its hierarchy.
+type PathNotExposedError = cgroups.PathNotExposedError
+
+// DetectionError is returned by Set in strict mode if the CPU quota couldn't
+// be determined, for example because `/proc/self/mountinfo` is malformed or
+// a cgroup file can't be read. See Strict.
+//
+// Err is typically a *ParseError, a *PathNotExposedError or an *os.PathError.
+type DetectionError struct {
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DetectionError) Error() string {
+	return fmt.Sprintf("maxprocs: failed to detect CPU quota: %v", e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DetectionError) Unwrap() error {
+	return e.Err
+}
+
+// UndefinedQuotaError is returned by Set in strict mode if the process
+// appears to run in a container, but no CPU quota is defined. See Strict.
+type UndefinedQuotaError struct {
+	// Evidence describes why the process appears to run in a container, such
+	// as "found /.dockerenv".
+	Evidence string
+}
+
+func (e *UndefinedQuotaError) Error() string {
+	return fmt.Sprintf("maxprocs: CPU quota undefined in container (%v)", e.Evidence)
+}
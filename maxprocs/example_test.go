@@ -21,7 +21,13 @@
 package maxprocs_test
 
 import (
+	"errors"
+	"io/fs"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"go.uber.org/automaxprocs/maxprocs"
 )
@@ -44,3 +50,136 @@ func ExampleLogger() {
 		log.Fatalf("failed to set GOMAXPROCS: %v", err)
 	}
 }
+
+func ExampleSource() {
+	// Read the CPU quota from a file written by the scheduler, falling back
+	// to the cgroups of the process if the file doesn't exist.
+	fromFile := maxprocs.QuotaSourceFunc(func() (float64, bool, error) {
+		b, err := os.ReadFile("/etc/scheduler/cpu-quota")
+		if errors.Is(err, fs.ErrNotExist) {
+			return -1, false, nil
+		} else if err != nil {
+			return -1, false, err
+		}
+		quota, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		return quota, err == nil, err
+	})
+
+	undo, err := maxprocs.Set(maxprocs.Source(maxprocs.FirstDefined(fromFile, maxprocs.CGroupsSource())))
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
+
+func ExampleDownwardAPIEnvSource() {
+	// With the container spec including:
+	//
+	//   env:
+	//   - name: CPU_LIMIT
+	//     valueFrom:
+	//       resourceFieldRef:
+	//         resource: limits.cpu
+	//         divisor: 1m
+	//
+	// prefer the limit from the downward API over the cgroups of the process.
+	undo, err := maxprocs.Set(maxprocs.Source(maxprocs.FirstDefined(
+		maxprocs.DownwardAPIEnvSource("CPU_LIMIT", "1m"),
+		maxprocs.CGroupsSource(),
+	)))
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
+
+func ExampleEnvOverride() {
+	// Don't let a GOMAXPROCS baked into a base image exceed the CPU quota,
+	// and find out what Set decided.
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.EnvOverride(maxprocs.EnvClamp), maxprocs.Report(&d))
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+	log.Printf("GOMAXPROCS=%v (%v, environment variable %v)", d.GOMAXPROCS, d.Status, d.Env)
+}
+
+func ExampleStrict() {
+	// Refuse to start with a GOMAXPROCS that doesn't match the container.
+	undo, err := maxprocs.Set(maxprocs.Strict())
+	defer undo()
+	var undefErr *maxprocs.UndefinedQuotaError
+	if errors.As(err, &undefErr) {
+		log.Fatalf("no CPU limit set for this container (%v)", undefErr.Evidence)
+	} else if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
+
+func ExampleEffectiveCPUCount() {
+	// Size a worker pool by the CPUs available to the container, whatever
+	// GOMAXPROCS the application chose.
+	work := make(chan func())
+	for i := 0; i < maxprocs.EffectiveCPUCount(); i++ {
+		go func() {
+			for f := range work {
+				f()
+			}
+		}()
+	}
+	close(work)
+}
+
+func ExampleOnChange() {
+	// Resize a worker pool whenever automaxprocs changes GOMAXPROCS.
+	cancel := maxprocs.OnChange(func(old, new int) {
+		log.Printf("resizing workers from %v to %v", old, new)
+	})
+	defer cancel()
+
+	undo, err := maxprocs.Set()
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
+
+func ExampleWatch() {
+	// Follow in-place resizes of the container, but only change GOMAXPROCS
+	// once a new quota held for a minute, at most every five minutes, and
+	// not for changes of less than half a CPU.
+	undo, err := maxprocs.Set(
+		maxprocs.Logger(log.Printf),
+		maxprocs.Watch(10*time.Second),
+		maxprocs.StabilityWindow(time.Minute),
+		maxprocs.MinChangeInterval(5*time.Minute),
+		maxprocs.DeadBand(0.5),
+	)
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
+
+func ExampleRuntime() {
+	// Let Go 1.25 and later runtimes set GOMAXPROCS from the CPU limit, and
+	// only set it with older runtimes.
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.Runtime(maxprocs.RuntimeDefer), maxprocs.Report(&d))
+	defer undo()
+	if err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+	log.Printf("GOMAXPROCS=%v (%v, %v)", d.GOMAXPROCS, d.Status, d.GoRuntime.Version)
+}
+
+func ExampleController() {
+	// Own GOMAXPROCS for the lifetime of a component, and restore the value
+	// from before it started, even if other components changed it since.
+	c := maxprocs.NewController(maxprocs.Logger(log.Printf))
+	defer c.Close()
+	if err := c.Apply(); err != nil {
+		log.Fatalf("failed to set GOMAXPROCS: %v", err)
+	}
+}
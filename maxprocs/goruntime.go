@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"runtime"
+	"time"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+// _startProcs is GOMAXPROCS when the process started, which the Go runtime
+// may still be updating.
+var _startProcs = runtime.GOMAXPROCS(0)
+
+// _manageInterval is how often RuntimeManage re-evaluates the CPU quota
+// without the Watch option.
+var _manageInterval = 10 * time.Second
+
+// GoRuntime describes how the Go runtime sets GOMAXPROCS by itself. Since Go
+// 1.25, it defaults GOMAXPROCS to the CPU limit of the container on Linux,
+// and updates it when the limit changes, until runtime.GOMAXPROCS is called.
+type GoRuntime = iruntime.GoRuntime
+
+// RuntimePolicy selects how Set coordinates with the Go runtime, if it sets
+// GOMAXPROCS from the CPU limit by itself.
+type RuntimePolicy int
+
+const (
+	// RuntimeOverride sets GOMAXPROCS once, like with older Go runtimes.
+	// This stops the runtime from updating GOMAXPROCS until the undo
+	// function is called, unless something else had set GOMAXPROCS before,
+	// in which case the undo function restores that value. This is the
+	// default.
+	RuntimeOverride RuntimePolicy = iota
+	// RuntimeDefer leaves GOMAXPROCS to the Go runtime if it sets it from the
+	// CPU limit, ignoring options such as Min, RoundQuotaFunc and
+	// CPURequest, and otherwise behaves like RuntimeOverride.
+	RuntimeDefer
+	// RuntimeManage sets GOMAXPROCS once like RuntimeOverride, and then keeps
+	// it up to date in place of the runtime, re-evaluating the CPU quota
+	// every 10 seconds unless the Watch option sets another interval.
+	RuntimeManage
+)
+
+// Runtime selects how Set coordinates with the Go runtime. By default, Set
+// uses RuntimeOverride. Set reports how the runtime behaves in
+// Decision.GoRuntime.
+func Runtime(p RuntimePolicy) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.runtimePolicy = p
+	})
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubGoRuntime(rt GoRuntime) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.detectGoRuntime = func() iruntime.GoRuntime { return rt }
+	})
+}
+
+func TestRuntime(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	// The runtime default would be runtime.NumCPU(), or the CPU limit.
+	var defaults int
+	stubs := gostub.Stub(&_setDefaultGOMAXPROCS, func() {
+		defaults++
+		runtime.GOMAXPROCS(prev)
+	})
+	defer stubs.Reset()
+
+	quotaOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return 3, iruntime.CPUQuotaUsed, nil
+	})
+	old := GoRuntime{Version: "go1.24.0"}
+	updating := GoRuntime{Version: "go1.25.0", ContainerAware: true, Updates: true}
+	static := GoRuntime{Version: "go1.25.0", ContainerAware: true}
+
+	tests := []struct {
+		name         string
+		policy       RuntimePolicy
+		rt           GoRuntime
+		want         int
+		wantStatus   Status
+		wantLog      string
+		wantUndoLog  string
+		wantDefaults int
+	}{
+		{
+			name:        "override old runtime",
+			policy:      RuntimeOverride,
+			rt:          old,
+			want:        3,
+			wantStatus:  QuotaUsed,
+			wantLog:     "maxprocs: Updating GOMAXPROCS=3: determined from CPU quota",
+			wantUndoLog: "maxprocs: Resetting GOMAXPROCS to",
+		},
+		{
+			name:         "override updating runtime",
+			policy:       RuntimeOverride,
+			rt:           updating,
+			want:         3,
+			wantStatus:   QuotaUsed,
+			wantLog:      "maxprocs: Overriding GOMAXPROCS from the go1.25.0 runtime, which stops updating it",
+			wantUndoLog:  "maxprocs: Resetting GOMAXPROCS to the Go runtime default",
+			wantDefaults: 1,
+		},
+		{
+			name:        "defer to old runtime",
+			policy:      RuntimeDefer,
+			rt:          old,
+			want:        3,
+			wantStatus:  QuotaUsed,
+			wantLog:     "maxprocs: Updating GOMAXPROCS=3: determined from CPU quota",
+			wantUndoLog: "maxprocs: Resetting GOMAXPROCS to",
+		},
+		{
+			name:        "defer to container-aware runtime",
+			policy:      RuntimeDefer,
+			rt:          static,
+			want:        prev,
+			wantStatus:  RuntimeUsed,
+			wantLog:     "determined by the go1.25.0 runtime",
+			wantUndoLog: "maxprocs: No GOMAXPROCS change to reset",
+		},
+		{
+			name:         "manage updating runtime",
+			policy:       RuntimeManage,
+			rt:           updating,
+			want:         3,
+			wantStatus:   QuotaUsed,
+			wantLog:      "maxprocs: Overriding GOMAXPROCS from the go1.25.0 runtime",
+			wantUndoLog:  "maxprocs: Resetting GOMAXPROCS to the Go runtime default",
+			wantDefaults: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults = 0
+			var d Decision
+			buf, logOpt := testLogger()
+			undo, err := Set(logOpt, quotaOpt, stubGoRuntime(tt.rt), Runtime(tt.policy), Report(&d))
+			require.NoError(t, err, "Set failed")
+			assert.Equal(t, tt.want, currentMaxProcs(), "unexpected GOMAXPROCS")
+			assert.Equal(t, tt.wantStatus, d.Status, "unexpected status")
+			assert.Equal(t, tt.rt, d.GoRuntime, "should report the Go runtime")
+			assert.Contains(t, buf.String(), tt.wantLog, "unexpected log output")
+
+			buf.Reset()
+			undo()
+			assert.Contains(t, buf.String(), tt.wantUndoLog, "unexpected undo log output")
+			assert.Equal(t, tt.wantDefaults, defaults, "unexpected calls to runtime.SetDefaultGOMAXPROCS")
+		})
+	}
+}
+
+func TestWatchIntervalAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		decision Decision
+		want     time.Duration
+	}{
+		{
+			name: "default",
+		},
+		{
+			name: "watch",
+			opts: []Option{Watch(time.Minute)},
+			want: time.Minute,
+		},
+		{
+			name: "manage",
+			opts: []Option{Runtime(RuntimeManage)},
+			want: _manageInterval,
+		},
+		{
+			name: "manage and watch",
+			opts: []Option{Runtime(RuntimeManage), Watch(time.Minute)},
+			want: time.Minute,
+		},
+		{
+			name:     "deferred",
+			opts:     []Option{Runtime(RuntimeDefer), Watch(time.Minute)},
+			decision: Decision{Status: RuntimeUsed},
+		},
+		{
+			name:     "env honored",
+			opts:     []Option{Watch(time.Minute)},
+			decision: Decision{Status: EnvUsed},
+		},
+		{
+			name:     "env clamped",
+			opts:     []Option{Watch(time.Minute), EnvOverride(EnvClamp)},
+			decision: Decision{Status: EnvUsed},
+			want:     time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newConfig(tt.opts...).watchIntervalAfter(tt.decision))
+		})
+	}
+}
+
+func TestRuntimeNested(t *testing.T) {
+	prev := currentMaxProcs()
+	defer runtime.GOMAXPROCS(prev)
+
+	// The runtime default would be runtime.NumCPU(), or the CPU limit.
+	const start = 5
+	var defaults int
+	stubs := gostub.Stub(&_setDefaultGOMAXPROCS, func() {
+		defaults++
+		runtime.GOMAXPROCS(start)
+	})
+	stubs.Stub(&_startProcs, start)
+	defer stubs.Reset()
+
+	updating := GoRuntime{Version: "go1.25.0", ContainerAware: true, Updates: true}
+	newControllers := func(t *testing.T) (a, b *Controller) {
+		a = NewController((&stubQuota{quota: 4}).option(), stubGoRuntime(updating))
+		b = NewController((&stubQuota{quota: 2}).option(), stubGoRuntime(updating))
+		require.NoError(t, a.Apply(), "Apply failed")
+		require.NoError(t, b.Apply(), "Apply failed")
+		require.Equal(t, 2, currentMaxProcs())
+		return a, b
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		runtime.GOMAXPROCS(start)
+		defaults = 0
+		a, b := newControllers(t)
+
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, 4, currentMaxProcs(), "should restore the GOMAXPROCS of the outer controller")
+		assert.Equal(t, 0, defaults, "shouldn't let the runtime update GOMAXPROCS")
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, start, currentMaxProcs())
+		assert.Equal(t, 1, defaults, "should let the runtime update GOMAXPROCS again")
+	})
+
+	t.Run("DefaultOutOfOrder", func(t *testing.T) {
+		runtime.GOMAXPROCS(start)
+		defaults = 0
+		a, b := newControllers(t)
+
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, 2, currentMaxProcs(), "should leave GOMAXPROCS to the inner controller")
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, start, currentMaxProcs())
+		assert.Equal(t, 1, defaults, "should let the runtime update GOMAXPROCS again")
+	})
+
+	t.Run("SetBefore", func(t *testing.T) {
+		runtime.GOMAXPROCS(6)
+		defaults = 0
+		a, b := newControllers(t)
+
+		require.NoError(t, b.Close(), "Close failed")
+		assert.Equal(t, 4, currentMaxProcs(), "should restore the GOMAXPROCS of the outer controller")
+		require.NoError(t, a.Close(), "Close failed")
+		assert.Equal(t, 6, currentMaxProcs(), "should restore GOMAXPROCS set before")
+		assert.Equal(t, 0, defaults, "shouldn't let the runtime update GOMAXPROCS")
+	})
+}
+
+func TestRuntimeManageQuotaRemoved(t *testing.T) {
+	prev := currentMaxProcs()
+	defer runtime.GOMAXPROCS(prev)
+
+	var defaults int32
+	stubs := gostub.Stub(&_setDefaultGOMAXPROCS, func() {
+		atomic.AddInt32(&defaults, 1)
+		runtime.GOMAXPROCS(prev)
+	})
+	stubs.Stub(&_startProcs, prev)
+	stubs.Stub(&_manageInterval, time.Millisecond)
+	defer stubs.Reset()
+	defer _watchers.Wait()
+
+	quota := &stubQuota{quota: float64(prev + 1)}
+	updating := GoRuntime{Version: "go1.25.0", ContainerAware: true, Updates: true}
+	c := NewController(quota.option(), stubGoRuntime(updating), Runtime(RuntimeManage))
+	defer c.Close()
+	require.NoError(t, c.Apply(), "Apply failed")
+	require.Equal(t, prev+1, currentMaxProcs())
+
+	quota.Set(0, nil)
+	require.Eventually(t, func() bool { return c.Decision().Status == QuotaRemoved }, time.Second, time.Millisecond,
+		"should report the removed quota")
+	assert.Equal(t, prev, currentMaxProcs(), "should let the runtime set GOMAXPROCS again")
+	assert.NotZero(t, atomic.LoadInt32(&defaults), "should let the runtime update GOMAXPROCS again")
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// MinChangeInterval makes the watcher started by Watch wait at least d
+// between changes of GOMAXPROCS, including the change made by Set, since
+// each change briefly stops the world. Changes found sooner are applied once
+// d has passed, if they still hold.
+func MinChangeInterval(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.hysteresis.minInterval = d
+	})
+}
+
+// StabilityWindow makes the watcher started by Watch change GOMAXPROCS only
+// once the new value was found by every re-evaluation over at least d. A
+// value that flips back and forth, for example while a vertical autoscaler
+// rolls out a new quota, is never applied.
+func StabilityWindow(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.hysteresis.window = d
+	})
+}
+
+// DeadBand makes the watcher started by Watch ignore changes of the CPU quota
+// by less than cpus from the quota GOMAXPROCS was last derived from, so that
+// a quota oscillating between 1.9 and 2.1 CPUs doesn't change GOMAXPROCS
+// even if it rounds differently.
+func DeadBand(cpus float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.hysteresis.deadBand = cpus
+	})
+}
+
+// hysteresis decides whether the watcher may change GOMAXPROCS.
+type hysteresis struct {
+	minInterval time.Duration
+	window      time.Duration
+	deadBand    float64
+	now         func() time.Time
+
+	// lastChange is when GOMAXPROCS was last changed, and lastQuota the
+	// quota it was derived from, or zero if undefined.
+	lastChange time.Time
+	lastQuota  float64
+	// candidate is the GOMAXPROCS value found by every re-evaluation since
+	// candidateSince, or zero if there's none.
+	candidate      int
+	candidateSince time.Time
+}
+
+// reset records that Set changed GOMAXPROCS based on quota.
+func (h *hysteresis) reset(quota float64) {
+	h.lastChange, h.lastQuota = h.now(), quota
+	h.candidate = 0
+}
+
+// allow reports whether GOMAXPROCS may change from current to procs, derived
+// from quota, and records the change if so. Otherwise, reason explains why,
+// and is empty if there's no change to make.
+func (h *hysteresis) allow(current, procs int, quota float64) (ok bool, reason string) {
+	if procs == current {
+		h.candidate = 0
+		return false, ""
+	}
+
+	now := h.now()
+	if h.deadBand > 0 && h.lastQuota > 0 && quota > 0 && math.Abs(quota-h.lastQuota) < h.deadBand {
+		h.candidate = 0
+		return false, fmt.Sprintf("CPU quota %v is within dead band %v of %v", quota, h.deadBand, h.lastQuota)
+	}
+	if procs != h.candidate {
+		h.candidate, h.candidateSince = procs, now
+	}
+	if stable := now.Sub(h.candidateSince); stable < h.window {
+		return false, fmt.Sprintf("GOMAXPROCS=%v stable for %v of %v", procs, stable, h.window)
+	}
+	if since := now.Sub(h.lastChange); since < h.minInterval {
+		return false, fmt.Sprintf("last changed %v ago, less than %v", since, h.minInterval)
+	}
+
+	h.reset(quota)
+	return true, ""
+}
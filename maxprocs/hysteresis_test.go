@@ -0,0 +1,138 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time      { return c.now }
+func (c *fakeClock) Add(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestHysteresis(t *testing.T) {
+	type step struct {
+		after      time.Duration
+		current    int
+		procs      int
+		quota      float64
+		wantOK     bool
+		wantReason string
+	}
+
+	tests := []struct {
+		name  string
+		h     hysteresis
+		quota float64 // quota when Set ran
+		steps []step
+	}{
+		{
+			name:  "disabled",
+			quota: 2,
+			steps: []step{
+				{current: 2, procs: 2, quota: 2},
+				{current: 2, procs: 3, quota: 2.5, wantOK: true},
+				{current: 3, procs: 2, quota: 2, wantOK: true},
+			},
+		},
+		{
+			name:  "min interval",
+			h:     hysteresis{minInterval: time.Minute},
+			quota: 2,
+			steps: []step{
+				{after: 30 * time.Second, current: 2, procs: 4, quota: 4, wantReason: "last changed 30s ago, less than 1m0s"},
+				{after: 30 * time.Second, current: 2, procs: 4, quota: 4, wantOK: true},
+				{after: 59 * time.Second, current: 4, procs: 1, quota: 1, wantReason: "last changed 59s ago, less than 1m0s"},
+				{after: time.Second, current: 4, procs: 1, quota: 1, wantOK: true},
+			},
+		},
+		{
+			name:  "stability window",
+			h:     hysteresis{window: 10 * time.Second},
+			quota: 2,
+			steps: []step{
+				{after: time.Minute, current: 2, procs: 3, quota: 2.1, wantReason: "GOMAXPROCS=3 stable for 0s of 10s"},
+				{after: 5 * time.Second, current: 2, procs: 2, quota: 1.9},
+				{after: 5 * time.Second, current: 2, procs: 3, quota: 2.1, wantReason: "GOMAXPROCS=3 stable for 0s of 10s"},
+				{after: 5 * time.Second, current: 2, procs: 4, quota: 4, wantReason: "GOMAXPROCS=4 stable for 0s of 10s"},
+				{after: 9 * time.Second, current: 2, procs: 4, quota: 4, wantReason: "GOMAXPROCS=4 stable for 9s of 10s"},
+				{after: time.Second, current: 2, procs: 4, quota: 4, wantOK: true},
+			},
+		},
+		{
+			name:  "dead band",
+			h:     hysteresis{deadBand: 0.5},
+			quota: 2,
+			steps: []step{
+				{current: 2, procs: 3, quota: 2.1, wantReason: "CPU quota 2.1 is within dead band 0.5 of 2"},
+				{current: 2, procs: 2, quota: 1.9},
+				{current: 2, procs: 3, quota: 2.5, wantOK: true},
+				{current: 3, procs: 2, quota: 2.1, wantReason: "CPU quota 2.1 is within dead band 0.5 of 2.5"},
+				{current: 3, procs: 2, quota: 1.5, wantOK: true},
+			},
+		},
+		{
+			name:  "dead band without quota",
+			h:     hysteresis{deadBand: 0.5},
+			quota: 0,
+			steps: []step{
+				{current: 8, procs: 1, quota: 0.2, wantOK: true},
+			},
+		},
+		{
+			name:  "combined",
+			h:     hysteresis{minInterval: time.Minute, window: 10 * time.Second, deadBand: 0.5},
+			quota: 2,
+			steps: []step{
+				{after: 10 * time.Second, current: 2, procs: 4, quota: 4, wantReason: "GOMAXPROCS=4 stable for 0s of 10s"},
+				{after: 10 * time.Second, current: 2, procs: 4, quota: 4, wantReason: "last changed 20s ago, less than 1m0s"},
+				{after: 40 * time.Second, current: 2, procs: 4, quota: 4, wantOK: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			h := tt.h
+			h.now = clock.Now
+			h.reset(tt.quota)
+
+			for i, s := range tt.steps {
+				clock.Add(s.after)
+				ok, reason := h.allow(s.current, s.procs, s.quota)
+				assert.Equal(t, s.wantOK, ok, "step %v", i)
+				assert.Equal(t, s.wantReason, reason, "step %v", i)
+			}
+		})
+	}
+}
@@ -24,8 +24,12 @@
 package maxprocs // import "go.uber.org/automaxprocs/maxprocs"
 
 import (
+	"errors"
 	"os"
 	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	iruntime "go.uber.org/automaxprocs/internal/runtime"
 )
@@ -38,9 +42,77 @@ func currentMaxProcs() int {
 
 type config struct {
 	printf         func(string, ...interface{})
-	procs          func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error)
+	procs          func(iruntime.Config) (iruntime.Result, error)
+	detectFacts    func(iruntime.QuotaSource, func(error)) (iruntime.Facts, error)
 	minGOMAXPROCS  int
 	roundQuotaFunc func(v float64) int
+	// capacityWeighted weighs the CPUs of the affinity mask by capacity, and
+	// physicalCores limits GOMAXPROCS to their physical cores.
+	capacityWeighted bool
+	physicalCores    bool
+	// rounding is the name of the RoundingStrategy that roundQuotaFunc
+	// implements, if any.
+	rounding string
+
+	// requestMultiplier, if positive, estimates GOMAXPROCS from the CPU
+	// request when no CPU quota is defined.
+	requestMultiplier float64
+	source            QuotaSource
+	envPolicy         EnvPolicy
+	report            *Decision
+
+	// reserveCPUs and reserveFraction reserve part of the quota for work
+	// other than Go code.
+	reserveCPUs     float64
+	reserveFraction float64
+
+	// policyFile is the path of the JSON Policy file, if any.
+	policyFile string
+
+	// strict is set if detection errors should be wrapped in a
+	// DetectionError, and an undefined quota in a container is an error.
+	strict            bool
+	containerEvidence func() string
+
+	// lenient is set if unparseable lines of `mountinfo` and `cgroup` should
+	// be skipped and reported as warnings rather than fail detection.
+	lenient bool
+	// timeout, if positive, bounds each read of the CPU quota or pids limit.
+	timeout time.Duration
+
+	// threadLimit is set if the max threads should be derived from the cgroup
+	// pids limit, leaving threadHeadroom tasks for the rest of the cgroup.
+	threadLimit    bool
+	threadHeadroom int
+	pidsLimit      func(onInvalidLine func(error)) (int, bool, error)
+	setMaxThreads  func(int) int
+
+	// watchInterval, if positive, is how often to re-evaluate the CPU quota,
+	// subject to hysteresis.
+	watchInterval time.Duration
+	hysteresis    hysteresis
+	// signal, if set, re-evaluates the CPU quota when received.
+	signal os.Signal
+	// driftInterval, if positive, is how often to check that GOMAXPROCS
+	// wasn't changed by something else, and correctDrift whether to set it
+	// back if it was.
+	driftInterval time.Duration
+	correctDrift  bool
+
+	// runtimePolicy selects how to coordinate with the Go runtime, whose
+	// behavior is detected by detectGoRuntime as goRuntime.
+	runtimePolicy   RuntimePolicy
+	detectGoRuntime func() iruntime.GoRuntime
+	goRuntime       iruntime.GoRuntime
+
+	// savedProcs, if set, returns the GOMAXPROCS to restore if the CPU quota
+	// is removed after GOMAXPROCS was set, or 0 to leave it to the Go runtime
+	// again, and whether there's one.
+	savedProcs func() (int, bool)
+	// setProcs sets GOMAXPROCS to procs, or leaves it to the Go runtime if
+	// procs is 0. The change is delivered to OnChange subscribers once the
+	// Controller is done.
+	setProcs func(procs int)
 }
 
 func (c *config) log(fmt string, args ...interface{}) {
@@ -49,7 +121,27 @@ func (c *config) log(fmt string, args ...interface{}) {
 	}
 }
 
-// An Option alters the behavior of Set.
+// invalidLineFunc returns the function that records lines skipped in lenient
+// mode as warnings in d, or nil if c isn't lenient.
+func (c *config) invalidLineFunc(d *Decision) func(error) {
+	if !c.lenient {
+		return nil
+	}
+	return func(err error) {
+		// The same files are read more than once, for example for the CPU
+		// quota and the pids limit.
+		msg := err.Error()
+		for _, w := range d.Warnings {
+			if w == msg {
+				return
+			}
+		}
+		d.Warnings = append(d.Warnings, msg)
+		c.log("maxprocs: Skipping invalid line: %v", msg)
+	}
+}
+
+// An Option alters the behavior of Set and Controllers.
 type Option interface {
 	apply(*config)
 }
@@ -72,10 +164,143 @@ func Min(n int) Option {
 	})
 }
 
+// CapacityWeighted weighs the CPUs the process may run on by their capacity,
+// as reported by the kernel on hybrid and big.LITTLE systems, so that
+// efficiency cores count as a fraction of a performance core. GOMAXPROCS is
+// then limited to the weighted count, rounded like the CPU quota, even if no
+// quota is defined. Set reports both counts as Decision.CPUs and
+// Decision.WeightedCPUs. Systems whose kernel doesn't report the capacity of
+// every CPU are unaffected.
+func CapacityWeighted() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.capacityWeighted = true
+	})
+}
+
+// PhysicalCores limits GOMAXPROCS to the number of physical cores the process
+// may run on, rather than their hardware threads, as latency-sensitive
+// programs may prefer on systems with simultaneous multithreading
+// (hyper-threading). A core counts if any of its threads is in the cpuset or
+// affinity mask of the process. The limit applies along with the CPU quota,
+// the smallest winning, and even if no quota is defined. Set reports the count
+// as Decision.PhysicalCores, and the CoresUsed status if it's the limit.
+// Systems whose kernel doesn't report the topology of every CPU are
+// unaffected.
+func PhysicalCores() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.physicalCores = true
+	})
+}
+
 // RoundQuotaFunc sets the function that will be used to covert the CPU quota from float to int.
+// See Rounding for common functions.
 func RoundQuotaFunc(rf func(v float64) int) Option {
 	return optionFunc(func(cfg *config) {
 		cfg.roundQuotaFunc = rf
+		cfg.rounding = ""
+	})
+}
+
+// CPURequest estimates GOMAXPROCS from the container's CPU request when no CPU
+// quota (CPU limit) is configured. The request is derived from `cpu.weight`
+// with cgroups v2 or `cpu.shares` with cgroups v1, assuming the Kubernetes
+// convention of 1024 shares per requested CPU. GOMAXPROCS is set to multiplier
+// times the request, rounded with the RoundQuotaFunc and never more than
+// runtime.NumCPU().
+//
+// Since the request is a soft limit, the result is an estimate, and Set logs
+// it as such. By default, or if multiplier isn't positive, Set leaves
+// GOMAXPROCS unchanged when there's no CPU quota.
+func CPURequest(multiplier float64) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.requestMultiplier = multiplier
+	})
+}
+
+// ReserveCPUs reserves n CPUs of the CPU quota for work other than Go code,
+// such as cgo-heavy libraries or other processes sharing the container's
+// quota. They're subtracted from the quota before rounding, but GOMAXPROCS is
+// never set below Min. Set logs the reservation, and reports it as
+// Decision.Reserved. Values below 0 are ignored.
+func ReserveCPUs(n float64) Option {
+	return optionFunc(func(cfg *config) {
+		if n >= 0 {
+			cfg.reserveCPUs = n
+		}
+	})
+}
+
+// ReserveFraction reserves fraction f of the CPU quota for work other than
+// Go code, like ReserveCPUs. Both can be combined, in which case their
+// reservations add up. Values outside [0, 1] are ignored.
+func ReserveFraction(f float64) Option {
+	return optionFunc(func(cfg *config) {
+		if f >= 0 && f <= 1 {
+			cfg.reserveFraction = f
+		}
+	})
+}
+
+// Strict makes Set fail if it can't determine GOMAXPROCS from the CPU quota
+// when it should be able to. Errors detecting the quota are returned as a
+// *DetectionError, and if the process appears to run in a container without a
+// CPU quota, Set returns an *UndefinedQuotaError. GOMAXPROCS is left unchanged
+// in both cases.
+//
+// The process is considered to run in a container if `/.dockerenv` or
+// `/run/.containerenv` exist, or if the KUBERNETES_SERVICE_HOST or container
+// environment variables are set. Honoring the GOMAXPROCS environment variable
+// is never an error.
+func Strict() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.strict = true
+	})
+}
+
+// Lenient makes Set skip lines of `/proc/self/mountinfo` and
+// `/proc/self/cgroup` that it can't parse, rather than fail, and determine the
+// CPU quota from the remaining lines. Skipped lines are logged and reported as
+// Decision.Warnings. Odd lines are known to come from some FUSE and overlay
+// mounts.
+//
+// Lenient doesn't apply to quota sources passed to Source, and malformed
+// cgroup files such as `cpu.max` are always errors.
+func Lenient() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.lenient = true
+	})
+}
+
+// Timeout makes Set give up reading the CPU quota, or the pids limit with
+// ThreadLimit, if it takes longer than d, as when a FUSE-backed `/proc` or
+// cgroup file system such as LXCFS hangs. Set then leaves GOMAXPROCS
+// unchanged and reports the TimedOut status, or leaves the max threads
+// unchanged and reports the timeout in Decision.Warnings. It only returns an
+// error, a *DetectionError wrapping ErrTimeout, in strict mode.
+//
+// The read can't be interrupted, so it carries on in the background and its
+// result is discarded. By default, or if d isn't positive, Set waits for the
+// read to complete.
+func Timeout(d time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.timeout = d
+	})
+}
+
+// ThreadLimit caps the number of OS threads the Go runtime may create (see
+// debug.SetMaxThreads) at the cgroup pids limit, less headroom tasks left for
+// other processes and threads in the container. Exhausting the limit then
+// crashes the program with a clear message from the Go runtime rather than
+// failing thread creation in the kernel. Negative headroom is treated as zero.
+//
+// By default, Set leaves the maximum number of threads unchanged.
+func ThreadLimit(headroom int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.threadLimit = true
+		cfg.threadHeadroom = 0
+		if headroom > 0 {
+			cfg.threadHeadroom = headroom
+		}
 	})
 }
 
@@ -83,57 +308,324 @@ type optionFunc func(*config)
 
 func (of optionFunc) apply(cfg *config) { of(cfg) }
 
-// Set GOMAXPROCS to match the Linux container CPU quota (if any), returning
-// any error encountered and an undo function.
-//
-// Set is a no-op on non-Linux systems and in Linux environments without a
-// configured CPU quota.
-func Set(opts ...Option) (func(), error) {
+// newConfig returns the configuration of Set with opts applied.
+func newConfig(opts ...Option) *config {
 	cfg := &config{
-		procs:          iruntime.CPUQuotaToGOMAXPROCS,
+		procs:          iruntime.DetectGOMAXPROCS,
+		detectFacts:    iruntime.DetectFacts,
 		roundQuotaFunc: iruntime.DefaultRoundFunc,
 		minGOMAXPROCS:  1,
+		policyFile:     os.Getenv(_policyFileKey),
+		pidsLimit:      iruntime.PidsLimit,
+		setMaxThreads:  debug.SetMaxThreads,
+
+		containerEvidence: iruntime.ContainerEvidence,
+		hysteresis:        hysteresis{now: time.Now},
+		detectGoRuntime:   iruntime.DetectGoRuntime,
+		setProcs:          _changes.queue,
 	}
 	for _, o := range opts {
 		o.apply(cfg)
 	}
+	return cfg
+}
+
+// Set GOMAXPROCS to match the Linux container CPU quota (if any), returning
+// any error encountered and an undo function.
+//
+// Set is a no-op on non-Linux systems and in Linux environments without a
+// configured CPU quota. If the ThreadLimit option is used, Set also caps the
+// number of OS threads, and the undo function restores the previous cap. If
+// the Watch option is used, Set keeps GOMAXPROCS up to date until the undo
+// function is called.
+//
+// Set is a shorthand for applying a new Controller, whose Close method is the
+// undo function. Calling Set again from the same place before undoing the
+// previous call takes over from it: the new undo function restores the values
+// from before the previous call, whose undo function then has no effect.
+func Set(opts ...Option) (func(), error) {
+	c := NewController(opts...)
+	c.setCaller, _, _, _ = runtime.Caller(1)
+	err := c.Apply()
+	return func() {
+		if err := c.Close(); err != nil {
+			c.cfg.log("maxprocs: Failed to reset GOMAXPROCS: %v", err)
+		}
+	}, err
+}
+
+// watchIntervalAfter returns how often to re-evaluate the CPU quota after
+// Set decided d, or 0 not to.
+func (c *config) watchIntervalAfter(d Decision) time.Duration {
+	switch {
+	case d.Status == RuntimeUsed:
+		return 0
+	case d.Status == EnvUsed && c.envPolicy != EnvClamp:
+		return 0
+	case c.watchInterval > 0:
+		return c.watchInterval
+	case c.runtimePolicy == RuntimeManage:
+		return _manageInterval
+	default:
+		return 0
+	}
+}
+
+// restoreMaxProcs resets GOMAXPROCS to prev, its value before Set, or lets
+// the Go runtime update it again if toDefault is set.
+func (c *config) restoreMaxProcs(prev int, toDefault bool) {
+	if toDefault {
+		c.log("maxprocs: Resetting GOMAXPROCS to the Go runtime default")
+		c.setProcs(0)
+		return
+	}
+	c.log("maxprocs: Resetting GOMAXPROCS to %v", prev)
+	c.setProcs(prev)
+}
 
-	undoNoop := func() {
-		cfg.log("maxprocs: No GOMAXPROCS change to reset")
+// savedMaxProcs returns the GOMAXPROCS to restore if the CPU quota was
+// removed, as savedProcs does.
+func (c *config) savedMaxProcs() (int, bool) {
+	if c.savedProcs == nil {
+		return 0, false
 	}
+	return c.savedProcs()
+}
 
-	// Honor the GOMAXPROCS environment variable if present. Otherwise, amend
-	// `runtime.GOMAXPROCS()` with the current process' CPU quota if the OS is
-	// Linux, and guarantee a minimum value of 1. The minimum guaranteed value
-	// can be overridden using `maxprocs.Min()`.
+// setMaxProcs sets GOMAXPROCS from the CPU quota, and reports whether it
+// did. If gate isn't nil, it only changes GOMAXPROCS if gate allows it. If
+// the CPU quota was removed since GOMAXPROCS was set, it restores the value
+// from cfg.savedProcs.
+func setMaxProcs(cfg *config, d *Decision, gate *hysteresis) (bool, error) {
+	// Honor the GOMAXPROCS environment variable if present, subject to the
+	// EnvPolicy. Otherwise, amend `runtime.GOMAXPROCS()` with the current
+	// process' CPU quota if the OS is Linux, and guarantee a minimum value of
+	// 1. The minimum guaranteed value can be overridden using `maxprocs.Min()`.
+	envProcs := -1
 	if max, exists := os.LookupEnv(_maxProcsKey); exists {
-		cfg.log("maxprocs: Honoring GOMAXPROCS=%q as set in environment", max)
-		return undoNoop, nil
+		d.EnvValue = max
+		n, valid := parseEnvMaxProcs(max)
+		switch {
+		case cfg.envPolicy == EnvIgnore:
+			d.Env = EnvIgnored
+			cfg.log("maxprocs: Ignoring GOMAXPROCS=%q as set in environment", max)
+		case cfg.envPolicy != EnvHonor && !valid:
+			d.Env = EnvInvalid
+			cfg.log("maxprocs: Ignoring invalid GOMAXPROCS=%q as set in environment", max)
+		case cfg.envPolicy == EnvClamp:
+			// Honored or clamped once the CPU quota is known.
+			envProcs = n
+		default:
+			d.Status, d.Env = EnvUsed, EnvHonored
+			cfg.log("maxprocs: Honoring GOMAXPROCS=%q as set in environment", max)
+			return false, nil
+		}
 	}
 
-	maxProcs, status, err := cfg.procs(cfg.minGOMAXPROCS, cfg.roundQuotaFunc)
+	if gate == nil && cfg.runtimePolicy == RuntimeDefer && cfg.goRuntime.ContainerAware {
+		d.Status = RuntimeUsed
+		cfg.log("maxprocs: Leaving GOMAXPROCS=%v: determined by the %v runtime", currentMaxProcs(), cfg.goRuntime.Version)
+		return false, nil
+	}
+
+	var (
+		result iruntime.Result
+		err    error
+		// detected collects warnings in the detection goroutine, which may
+		// outlive Set.
+		detected = Decision{Warnings: append([]string(nil), d.Warnings...)}
+		out      = policyOutcome{
+			cfg: iruntime.Config{
+				Min:               cfg.minGOMAXPROCS,
+				CapacityWeighted:  cfg.capacityWeighted,
+				PhysicalCores:     cfg.physicalCores,
+				Round:             cfg.roundQuotaFunc,
+				RequestMultiplier: cfg.requestMultiplier,
+				ReserveCPUs:       cfg.reserveCPUs,
+				ReserveFraction:   cfg.reserveFraction,
+				Source:            cfg.source,
+				OnInvalidLine:     cfg.invalidLineFunc(&detected),
+			},
+			rounding: cfg.rounding,
+		}
+	)
+	completed := runWithTimeout(cfg.timeout, func() {
+		if err = cfg.applyPolicy(&out); err == nil {
+			result, err = cfg.procs(out.cfg)
+		}
+	})
+	if !completed {
+		d.Status = TimedOut
+		cfg.log("maxprocs: Leaving GOMAXPROCS=%v: CPU quota detection timed out after %v", currentMaxProcs(), cfg.timeout)
+		if cfg.strict {
+			return false, &DetectionError{Err: ErrTimeout}
+		}
+		return false, nil
+	}
+	d.Warnings, d.Rules, d.Rounding = append(detected.Warnings, out.warnings...), out.rules, out.rounding
+	for _, rule := range out.rules {
+		cfg.log("maxprocs: Applying policy rule %q", rule)
+	}
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		return false, err
+	}
 	if err != nil {
-		return undoNoop, err
+		if cfg.strict {
+			err = &DetectionError{Err: err}
+		}
+		return false, err
+	}
+
+	maxProcs, status := result.GOMAXPROCS, result.Status
+	if result.Quota >= 0 {
+		d.Quota, d.Reserved = result.Quota, result.Reserved
+	}
+	d.CPUs, d.WeightedCPUs, d.PhysicalCores = result.CPUs, result.WeightedCPUs, result.PhysicalCores
+	if envProcs > 0 && (status == iruntime.CPUQuotaUndefined || envProcs <= maxProcs) {
+		d.Status, d.Env = EnvUsed, EnvHonored
+		cfg.log("maxprocs: Honoring GOMAXPROCS=%q as set in environment", d.EnvValue)
+		return false, nil
 	}
 
+	d.Status = statusFromCPUQuota(status)
 	if status == iruntime.CPUQuotaUndefined {
-		cfg.log("maxprocs: Leaving GOMAXPROCS=%v: CPU quota undefined", currentMaxProcs())
-		return undoNoop, nil
+		saved, ok := cfg.savedMaxProcs()
+		if !ok {
+			if cfg.strict {
+				if evidence := cfg.containerEvidence(); evidence != "" {
+					return false, &UndefinedQuotaError{Evidence: evidence}
+				}
+			}
+			cfg.log("maxprocs: Leaving GOMAXPROCS=%v: CPU quota undefined", currentMaxProcs())
+			return false, nil
+		}
+		// GOMAXPROCS no longer matches a quota, so it mustn't stay pinned to
+		// the one that was removed.
+		d.Status, maxProcs = QuotaRemoved, saved
 	}
 
 	prev := currentMaxProcs()
-	undo := func() {
-		cfg.log("maxprocs: Resetting GOMAXPROCS to %v", prev)
-		runtime.GOMAXPROCS(prev)
+	// The default of the Go runtime is unknown until it's restored.
+	if gate != nil && maxProcs > 0 {
+		if ok, reason := gate.allow(prev, maxProcs, result.Quota); !ok {
+			if reason != "" {
+				cfg.log("maxprocs: Keeping GOMAXPROCS=%v: %v", prev, reason)
+			}
+			return false, nil
+		}
 	}
 
-	switch status {
-	case iruntime.CPUQuotaMinUsed:
+	if result.Reserved > 0 {
+		cfg.log("maxprocs: Reserving %v of %v CPUs for other work", result.Reserved, result.Quota)
+	}
+	var rounded string
+	if out.rounding != "" {
+		rounded = " with " + out.rounding + " rounding"
+	}
+	switch {
+	case d.Status == QuotaRemoved && maxProcs == 0:
+		cfg.log("maxprocs: Resetting GOMAXPROCS to the Go runtime default: CPU quota removed")
+	case d.Status == QuotaRemoved:
+		cfg.log("maxprocs: Resetting GOMAXPROCS to %v: CPU quota removed", maxProcs)
+	case envProcs > 0:
+		d.Env = EnvClamped
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: clamped GOMAXPROCS=%q as set in environment to CPU quota%v", maxProcs, d.EnvValue, rounded)
+	case status == iruntime.CPUQuotaMinUsed:
 		cfg.log("maxprocs: Updating GOMAXPROCS=%v: using minimum allowed GOMAXPROCS", maxProcs)
-	case iruntime.CPUQuotaUsed:
-		cfg.log("maxprocs: Updating GOMAXPROCS=%v: determined from CPU quota", maxProcs)
+	case status == iruntime.CPUQuotaUsed:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: determined from CPU quota%v", maxProcs, rounded)
+	case status == iruntime.CPUQuotaRequestUsed:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: estimated from CPU request%v, no CPU quota set", maxProcs, rounded)
+	case status == iruntime.CPUQuotaAffinityUsed && result.WeightedCPUs > 0:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: limited by CPU affinity, %v CPUs weighing %v by capacity", maxProcs, result.CPUs, result.WeightedCPUs)
+	case status == iruntime.CPUQuotaAffinityUsed:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: limited by CPU affinity", maxProcs)
+	case status == iruntime.CPUQuotaCoresUsed:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: limited to the physical cores of %v CPUs", maxProcs, result.CPUs)
+	case status == iruntime.CPUQuotaMaxUsed:
+		cfg.log("maxprocs: Updating GOMAXPROCS=%v: using maximum allowed GOMAXPROCS", maxProcs)
+	}
+
+	if gate == nil && cfg.goRuntime.Updates && d.Status != QuotaRemoved {
+		cfg.log("maxprocs: Overriding GOMAXPROCS from the %v runtime, which stops updating it", cfg.goRuntime.Version)
 	}
+	cfg.setProcs(maxProcs)
+	return true, nil
+}
 
-	runtime.GOMAXPROCS(maxProcs)
-	return undo, nil
+// setMaxThreads sets the max threads from the pids limit, and reports the
+// previous limit if it did.
+func setMaxThreads(cfg *config, d *Decision) (int, bool, error) {
+	var (
+		limit    int
+		defined  bool
+		err      error
+		detected = Decision{Warnings: append([]string(nil), d.Warnings...)}
+	)
+	completed := runWithTimeout(cfg.timeout, func() {
+		limit, defined, err = cfg.pidsLimit(cfg.invalidLineFunc(&detected))
+	})
+	if !completed {
+		// d.Status describes GOMAXPROCS, which may have been set already.
+		d.Warnings = append(d.Warnings, "pids limit detection timed out after "+cfg.timeout.String())
+		cfg.log("maxprocs: Leaving max threads unchanged: pids limit detection timed out after %v", cfg.timeout)
+		if cfg.strict {
+			return 0, false, &DetectionError{Err: ErrTimeout}
+		}
+		return 0, false, nil
+	}
+	d.Warnings = detected.Warnings
+	if err != nil {
+		return 0, false, err
+	}
+
+	if !defined {
+		cfg.log("maxprocs: Leaving max threads unchanged: pids limit undefined")
+		return 0, false, nil
+	}
+
+	// Lowering the limit below the number of threads that already exist
+	// crashes the program immediately.
+	maxThreads := limit - cfg.threadHeadroom
+	if threads, _ := runtime.ThreadCreateProfile(nil); maxThreads <= threads {
+		cfg.log("maxprocs: Leaving max threads unchanged: pids limit %v with headroom %v is too low", limit, cfg.threadHeadroom)
+		return 0, false, nil
+	}
+
+	prev := cfg.setMaxThreads(maxThreads)
+	cfg.log("maxprocs: Updating max threads=%v: determined from pids limit %v", maxThreads, limit)
+	return prev, true, nil
+}
+
+// _detections tracks the reads started by runWithTimeout, including those that
+// outlived their timeout. Tests wait for it before restoring stubs.
+var _detections sync.WaitGroup
+
+// runWithTimeout runs f, and reports whether it completed within timeout. If
+// timeout isn't positive, it runs f synchronously. Otherwise f runs in a new
+// goroutine that carries on after the timeout, so it must not share state with
+// the caller once runWithTimeout returns false.
+func runWithTimeout(timeout time.Duration, f func()) bool {
+	if timeout <= 0 {
+		f()
+		return true
+	}
+
+	done := make(chan struct{})
+	_detections.Add(1)
+	go func() {
+		defer _detections.Done()
+		f()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
@@ -29,6 +29,7 @@ import (
 	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	iruntime "go.uber.org/automaxprocs/internal/runtime"
 
@@ -58,7 +59,10 @@ func testLogger() (*bytes.Buffer, Option) {
 
 func stubProcs(f func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error)) Option {
 	return optionFunc(func(cfg *config) {
-		cfg.procs = f
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			procs, status, err := f(c.Min, c.Round)
+			return iruntime.Result{GOMAXPROCS: procs, Status: status}, err
+		}
 	})
 }
 
@@ -170,6 +174,18 @@ func TestSet(t *testing.T) {
 		assert.Equal(t, 42, currentMaxProcs(), "should change GOMAXPROCS to match quota")
 	})
 
+	t.Run("AffinityUsed", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		quotaOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return 2, iruntime.CPUQuotaAffinityUsed, nil
+		})
+		undo, err := Set(logOpt, quotaOpt)
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 2, currentMaxProcs(), "should change GOMAXPROCS to match affinity")
+		assert.Contains(t, buf.String(), "limited by CPU affinity", "unexpected log output")
+	})
+
 	t.Run("RoundQuotaSetToCeil", func(t *testing.T) {
 		opt := stubProcs(func(min int, round func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
 			assert.Equal(t, round(2.4), 3, "round should be math.Ceil")
@@ -193,6 +209,590 @@ func TestSet(t *testing.T) {
 	})
 }
 
+func TestCPURequest(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	t.Run("RequestUsed", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		opt := optionFunc(func(cfg *config) {
+			cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+				assert.Equal(t, 1.5, c.RequestMultiplier, "multiplier should be passed through")
+				return iruntime.Result{GOMAXPROCS: 3, Status: iruntime.CPUQuotaRequestUsed, Quota: 3}, nil
+			}
+		})
+		undo, err := Set(logOpt, opt, CPURequest(1.5))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, currentMaxProcs(), "should change GOMAXPROCS to match estimate")
+		assert.Contains(t, buf.String(), "estimated from CPU request", "unexpected log output")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		opt := optionFunc(func(cfg *config) {
+			cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+				assert.Zero(t, c.RequestMultiplier, "multiplier should be disabled by default")
+				return iruntime.Result{GOMAXPROCS: -1, Status: iruntime.CPUQuotaUndefined, Quota: -1}, nil
+			}
+		})
+		undo, err := Set(opt)
+		defer undo()
+		require.NoError(t, err, "Set failed")
+	})
+}
+
+func TestReserve(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	t.Run("Reserved", func(t *testing.T) {
+		var d Decision
+		buf, logOpt := testLogger()
+		opt := optionFunc(func(cfg *config) {
+			cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+				assert.Equal(t, 0.5, c.ReserveCPUs, "CPUs should be passed through")
+				assert.Equal(t, 0.1, c.ReserveFraction, "fraction should be passed through")
+				return iruntime.Result{GOMAXPROCS: 3, Status: iruntime.CPUQuotaUsed, Quota: 4, Reserved: 0.9}, nil
+			}
+		})
+		undo, err := Set(logOpt, opt, ReserveCPUs(0.5), ReserveFraction(0.1), Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, currentMaxProcs())
+		assert.Equal(t, 0.9, d.Reserved)
+		assert.Contains(t, buf.String(), "maxprocs: Reserving 0.9 of 4 CPUs for other work")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cfg := newConfig(ReserveCPUs(-1), ReserveFraction(1.5))
+		assert.Zero(t, cfg.reserveCPUs, "negative CPUs should be ignored")
+		assert.Zero(t, cfg.reserveFraction, "fractions above 1 should be ignored")
+	})
+}
+
+func TestCapacityWeighted(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	var d Decision
+	buf, logOpt := testLogger()
+	opt := optionFunc(func(cfg *config) {
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			assert.True(t, c.CapacityWeighted, "should be passed through")
+			return iruntime.Result{GOMAXPROCS: 2, Status: iruntime.CPUQuotaAffinityUsed, Quota: -1, CPUs: 4, WeightedCPUs: 2.5}, nil
+		}
+	})
+	undo, err := Set(logOpt, opt, CapacityWeighted(), Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, currentMaxProcs())
+	assert.Equal(t, AffinityUsed, d.Status)
+	assert.Equal(t, 4, d.CPUs)
+	assert.Equal(t, 2.5, d.WeightedCPUs)
+	assert.Zero(t, d.Quota, "no quota is defined")
+	assert.Equal(t, "maxprocs: Updating GOMAXPROCS=2: limited by CPU affinity, 4 CPUs weighing 2.5 by capacity", buf.String())
+}
+
+func TestPhysicalCores(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	var d Decision
+	buf, logOpt := testLogger()
+	opt := optionFunc(func(cfg *config) {
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			assert.True(t, c.PhysicalCores, "should be passed through")
+			return iruntime.Result{GOMAXPROCS: 2, Status: iruntime.CPUQuotaCoresUsed, Quota: 3, CPUs: 4, PhysicalCores: 2}, nil
+		}
+	})
+	undo, err := Set(logOpt, opt, PhysicalCores(), Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, currentMaxProcs())
+	assert.Equal(t, CoresUsed, d.Status)
+	assert.Equal(t, 4, d.CPUs)
+	assert.Equal(t, 2, d.PhysicalCores)
+	assert.Equal(t, "maxprocs: Updating GOMAXPROCS=2: limited to the physical cores of 4 CPUs", buf.String())
+}
+
+func TestEnvOverride(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	quotaOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return 4, iruntime.CPUQuotaUsed, nil
+	})
+	undefinedOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return -1, iruntime.CPUQuotaUndefined, nil
+	})
+
+	tests := []struct {
+		name       string
+		env        string
+		policy     EnvPolicy
+		quotaOpt   Option
+		want       int // zero if GOMAXPROCS should be unchanged
+		wantStatus Status
+		wantEnv    EnvOutcome
+		wantLog    string
+	}{
+		{
+			name:       "honor/garbage",
+			env:        "lots",
+			policy:     EnvHonor,
+			quotaOpt:   quotaOpt,
+			wantStatus: EnvUsed,
+			wantEnv:    EnvHonored,
+			wantLog:    `Honoring GOMAXPROCS="lots"`,
+		},
+		{
+			name:       "ignore",
+			env:        "64",
+			policy:     EnvIgnore,
+			quotaOpt:   quotaOpt,
+			want:       4,
+			wantStatus: QuotaUsed,
+			wantEnv:    EnvIgnored,
+			wantLog:    `Ignoring GOMAXPROCS="64"`,
+		},
+		{
+			name:       "validate/valid",
+			env:        "64",
+			policy:     EnvValidate,
+			quotaOpt:   quotaOpt,
+			wantStatus: EnvUsed,
+			wantEnv:    EnvHonored,
+			wantLog:    `Honoring GOMAXPROCS="64"`,
+		},
+		{
+			name:       "validate/empty",
+			env:        "",
+			policy:     EnvValidate,
+			quotaOpt:   quotaOpt,
+			want:       4,
+			wantStatus: QuotaUsed,
+			wantEnv:    EnvInvalid,
+			wantLog:    `Ignoring invalid GOMAXPROCS=""`,
+		},
+		{
+			name:       "validate/zero",
+			env:        "0",
+			policy:     EnvValidate,
+			quotaOpt:   quotaOpt,
+			want:       4,
+			wantStatus: QuotaUsed,
+			wantEnv:    EnvInvalid,
+			wantLog:    `Ignoring invalid GOMAXPROCS="0"`,
+		},
+		{
+			name:       "clamp/above quota",
+			env:        "64",
+			policy:     EnvClamp,
+			quotaOpt:   quotaOpt,
+			want:       4,
+			wantStatus: QuotaUsed,
+			wantEnv:    EnvClamped,
+			wantLog:    `Updating GOMAXPROCS=4: clamped GOMAXPROCS="64"`,
+		},
+		{
+			name:       "clamp/below quota",
+			env:        "3",
+			policy:     EnvClamp,
+			quotaOpt:   quotaOpt,
+			wantStatus: EnvUsed,
+			wantEnv:    EnvHonored,
+			wantLog:    `Honoring GOMAXPROCS="3"`,
+		},
+		{
+			name:       "clamp/quota undefined",
+			env:        "64",
+			policy:     EnvClamp,
+			quotaOpt:   undefinedOpt,
+			wantStatus: EnvUsed,
+			wantEnv:    EnvHonored,
+			wantLog:    `Honoring GOMAXPROCS="64"`,
+		},
+		{
+			name:       "clamp/invalid",
+			env:        "-2",
+			policy:     EnvClamp,
+			quotaOpt:   quotaOpt,
+			want:       4,
+			wantStatus: QuotaUsed,
+			wantEnv:    EnvInvalid,
+			wantLog:    `Ignoring invalid GOMAXPROCS="-2"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(_maxProcsKey, tt.env)
+			want := tt.want
+			if want == 0 {
+				want = currentMaxProcs()
+			}
+
+			var d Decision
+			buf, logOpt := testLogger()
+			undo, err := Set(logOpt, tt.quotaOpt, EnvOverride(tt.policy), Report(&d))
+			defer undo()
+			require.NoError(t, err, "Set failed")
+			assert.Equal(t, want, currentMaxProcs(), "unexpected GOMAXPROCS")
+			assert.Contains(t, buf.String(), tt.wantLog, "unexpected log output")
+			assert.Equal(t, Decision{
+				Status:     tt.wantStatus,
+				GOMAXPROCS: want,
+				Env:        tt.wantEnv,
+				EnvValue:   tt.env,
+				GoRuntime:  iruntime.DetectGoRuntime(),
+			}, d, "unexpected decision")
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	t.Run("QuotaUsed", func(t *testing.T) {
+		var d Decision
+		opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return 3, iruntime.CPUQuotaAffinityUsed, nil
+		})
+		undo, err := Set(opt, Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, Decision{Status: AffinityUsed, GOMAXPROCS: 3, GoRuntime: iruntime.DetectGoRuntime()}, d)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		d := Decision{Status: QuotaUsed}
+		opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return 0, iruntime.CPUQuotaUndefined, errors.New("failed")
+		})
+		undo, err := Set(opt, Report(&d))
+		defer undo()
+		require.Error(t, err, "Set should have failed")
+		assert.Equal(t, Decision{Status: QuotaUndefined, GOMAXPROCS: currentMaxProcs(), GoRuntime: iruntime.DetectGoRuntime()}, d)
+	})
+}
+
+func stubContainer(evidence string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.containerEvidence = func() string { return evidence }
+	})
+}
+
+func TestStrict(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	errOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return -1, iruntime.CPUQuotaUndefined, errors.New("mountinfo: bad line")
+	})
+	undefinedOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return -1, iruntime.CPUQuotaUndefined, nil
+	})
+
+	t.Run("DetectionError", func(t *testing.T) {
+		undo, err := Set(errOpt, Strict())
+		defer undo()
+		var detectErr *DetectionError
+		require.True(t, errors.As(err, &detectErr), "expected a DetectionError, got %v", err)
+		assert.EqualError(t, detectErr.Err, "mountinfo: bad line")
+		assert.EqualError(t, err, "maxprocs: failed to detect CPU quota: mountinfo: bad line")
+		assert.Equal(t, prev, currentMaxProcs(), "GOMAXPROCS shouldn't change")
+	})
+
+	t.Run("DetectionErrorNotStrict", func(t *testing.T) {
+		undo, err := Set(errOpt)
+		defer undo()
+		assert.EqualError(t, err, "mountinfo: bad line", "error shouldn't be wrapped")
+	})
+
+	t.Run("UndefinedInContainer", func(t *testing.T) {
+		undo, err := Set(undefinedOpt, stubContainer("found /.dockerenv"), Strict())
+		defer undo()
+		var undefErr *UndefinedQuotaError
+		require.True(t, errors.As(err, &undefErr), "expected an UndefinedQuotaError, got %v", err)
+		assert.Equal(t, "found /.dockerenv", undefErr.Evidence)
+		assert.EqualError(t, err, "maxprocs: CPU quota undefined in container (found /.dockerenv)")
+	})
+
+	t.Run("UndefinedOutsideContainer", func(t *testing.T) {
+		undo, err := Set(undefinedOpt, stubContainer(""), Strict())
+		defer undo()
+		assert.NoError(t, err, "undefined quota outside containers isn't an error")
+	})
+
+	t.Run("UndefinedNotStrict", func(t *testing.T) {
+		undo, err := Set(undefinedOpt, stubContainer("found /.dockerenv"))
+		defer undo()
+		assert.NoError(t, err, "undefined quota is only an error in strict mode")
+	})
+
+	t.Run("EnvHonored", func(t *testing.T) {
+		t.Setenv(_maxProcsKey, "2")
+		undo, err := Set(undefinedOpt, stubContainer("found /.dockerenv"), Strict())
+		defer undo()
+		assert.NoError(t, err, "honoring GOMAXPROCS isn't an error")
+	})
+}
+
+func TestLenient(t *testing.T) {
+	badLine := errors.New("mountinfo:3: invalid format for MountPoint")
+	procsOpt := optionFunc(func(cfg *config) {
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			undefined := iruntime.Result{GOMAXPROCS: -1, Status: iruntime.CPUQuotaUndefined, Quota: -1}
+			if c.OnInvalidLine == nil {
+				return undefined, badLine
+			}
+			c.OnInvalidLine(badLine)
+			c.OnInvalidLine(badLine)
+			return undefined, nil
+		}
+	})
+
+	t.Run("Lenient", func(t *testing.T) {
+		var d Decision
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, procsOpt, Lenient(), Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, []string{badLine.Error()}, d.Warnings, "should report each skipped line once")
+		assert.Contains(t, buf.String(), "maxprocs: Skipping invalid line: mountinfo:3: invalid format for MountPoint")
+	})
+
+	t.Run("ThreadLimit", func(t *testing.T) {
+		var d Decision
+		pidsOpt := optionFunc(func(cfg *config) {
+			cfg.pidsLimit = func(onInvalidLine func(error)) (int, bool, error) {
+				onInvalidLine(badLine)
+				onInvalidLine(errors.New("cgroup:2: invalid format for CGroupSubsys"))
+				return -1, false, nil
+			}
+		})
+		undo, err := Set(procsOpt, pidsOpt, ThreadLimit(0), Lenient(), Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, []string{badLine.Error(), "cgroup:2: invalid format for CGroupSubsys"}, d.Warnings)
+	})
+
+	t.Run("NotLenient", func(t *testing.T) {
+		var d Decision
+		undo, err := Set(procsOpt, Report(&d))
+		defer undo()
+		assert.Equal(t, badLine, err)
+		assert.Empty(t, d.Warnings)
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	unblock := make(chan struct{})
+	defer func() {
+		close(unblock)
+		_detections.Wait()
+	}()
+	blockingOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		<-unblock
+		return 3, iruntime.CPUQuotaUsed, nil
+	})
+
+	t.Run("TimedOut", func(t *testing.T) {
+		var d Decision
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, blockingOpt, Timeout(10*time.Millisecond), Report(&d))
+		defer undo()
+		require.NoError(t, err, "timeouts are only errors in strict mode")
+		assert.Equal(t, Decision{Status: TimedOut, GOMAXPROCS: prev, GoRuntime: iruntime.DetectGoRuntime()}, d)
+		assert.Contains(t, buf.String(), "CPU quota detection timed out after 10ms")
+	})
+
+	t.Run("Strict", func(t *testing.T) {
+		undo, err := Set(blockingOpt, Timeout(10*time.Millisecond), Strict())
+		defer undo()
+		assert.ErrorIs(t, err, ErrTimeout)
+		var detectErr *DetectionError
+		assert.True(t, errors.As(err, &detectErr), "expected a DetectionError, got %v", err)
+		assert.Equal(t, prev, currentMaxProcs(), "GOMAXPROCS shouldn't change")
+	})
+
+	t.Run("Completed", func(t *testing.T) {
+		opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return 3, iruntime.CPUQuotaUsed, nil
+		})
+		undo, err := Set(opt, Timeout(time.Minute))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, currentMaxProcs(), "should use the quota read in time")
+	})
+
+	t.Run("ThreadLimit", func(t *testing.T) {
+		opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return -1, iruntime.CPUQuotaUndefined, nil
+		})
+		blockingThreads := optionFunc(func(cfg *config) {
+			cfg.pidsLimit = func(func(error)) (int, bool, error) {
+				<-unblock
+				return 5000, true, nil
+			}
+			cfg.setMaxThreads = func(int) int {
+				t.Error("max threads shouldn't change")
+				return 0
+			}
+		})
+		var d Decision
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt, blockingThreads, ThreadLimit(0), Timeout(10*time.Millisecond), Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Contains(t, buf.String(), "pids limit detection timed out after 10ms")
+		assert.Equal(t, []string{"pids limit detection timed out after 10ms"}, d.Warnings)
+
+		quotaOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+			return 3, iruntime.CPUQuotaUsed, nil
+		})
+		undo, err = Set(quotaOpt, blockingThreads, ThreadLimit(0), Timeout(10*time.Millisecond), Strict())
+		defer undo()
+		assert.ErrorIs(t, err, ErrTimeout)
+		var detectErr *DetectionError
+		assert.True(t, errors.As(err, &detectErr), "expected a DetectionError, got %v", err)
+	})
+}
+
+func TestStatusString(t *testing.T) {
+	assert.Equal(t, "quota used", QuotaUsed.String())
+	assert.Equal(t, "env used", EnvUsed.String())
+	assert.Equal(t, "timed out", TimedOut.String())
+	assert.Equal(t, "runtime used", RuntimeUsed.String())
+	assert.Equal(t, "max used", MaxUsed.String())
+	assert.Equal(t, "cores used", CoresUsed.String())
+	assert.Equal(t, "Status(42)", Status(42).String())
+	assert.Equal(t, "clamped", EnvClamped.String())
+	assert.Equal(t, "EnvOutcome(42)", EnvOutcome(42).String())
+}
+
+func stubThreads(limit int, defined bool, err error, set func(int) int) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.pidsLimit = func(func(error)) (int, bool, error) { return limit, defined, err }
+		cfg.setMaxThreads = set
+	})
+}
+
+func TestThreadLimit(t *testing.T) {
+	quotaOpt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return 0, iruntime.CPUQuotaUndefined, nil
+	})
+
+	t.Run("LimitDefined", func(t *testing.T) {
+		var calls []int
+		buf, logOpt := testLogger()
+		threadsOpt := stubThreads(5000, true, nil, func(n int) int {
+			calls = append(calls, n)
+			return 10000
+		})
+
+		undo, err := Set(logOpt, quotaOpt, threadsOpt, ThreadLimit(100))
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, []int{4900}, calls, "should leave headroom below the pids limit")
+		assert.Contains(t, buf.String(), "Updating max threads=4900", "unexpected log output")
+
+		undo()
+		assert.Equal(t, []int{4900, 10000}, calls, "undo should restore the previous limit")
+	})
+
+	t.Run("NegativeHeadroom", func(t *testing.T) {
+		var calls []int
+		threadsOpt := stubThreads(5000, true, nil, func(n int) int {
+			calls = append(calls, n)
+			return 10000
+		})
+
+		undo, err := Set(quotaOpt, threadsOpt, ThreadLimit(-1))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, []int{5000}, calls, "should use the pids limit")
+	})
+
+	t.Run("NotRequested", func(t *testing.T) {
+		threadsOpt := stubThreads(5000, true, nil, func(int) int {
+			t.Fatal("shouldn't change max threads")
+			return 0
+		})
+
+		undo, err := Set(quotaOpt, threadsOpt)
+		defer undo()
+		require.NoError(t, err, "Set failed")
+	})
+
+	t.Run("LimitUndefined", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		threadsOpt := stubThreads(-1, false, nil, func(int) int {
+			t.Fatal("shouldn't change max threads")
+			return 0
+		})
+
+		undo, err := Set(logOpt, quotaOpt, threadsOpt, ThreadLimit(100))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Contains(t, buf.String(), "pids limit undefined", "unexpected log output")
+	})
+
+	t.Run("LimitTooLow", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		threadsOpt := stubThreads(100, true, nil, func(int) int {
+			t.Fatal("shouldn't change max threads")
+			return 0
+		})
+
+		undo, err := Set(logOpt, quotaOpt, threadsOpt, ThreadLimit(100))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Contains(t, buf.String(), "too low", "unexpected log output")
+	})
+
+	t.Run("ErrorReadingLimit", func(t *testing.T) {
+		threadsOpt := stubThreads(-1, false, errors.New("failed"), func(int) int {
+			t.Fatal("shouldn't change max threads")
+			return 0
+		})
+
+		undo, err := Set(quotaOpt, threadsOpt, ThreadLimit(100))
+		defer undo()
+		require.Error(t, err, "Set should have failed")
+		assert.Equal(t, "failed", err.Error(), "should pass errors up the stack")
+	})
+
+	t.Run("EnvVarPresent", func(t *testing.T) {
+		withMax(t, 42, func() {
+			var calls []int
+			threadsOpt := stubThreads(5000, true, nil, func(n int) int {
+				calls = append(calls, n)
+				return 10000
+			})
+
+			undo, err := Set(threadsOpt, ThreadLimit(0))
+			defer undo()
+			require.NoError(t, err, "Set failed")
+			assert.Equal(t, []int{5000}, calls, "should limit threads regardless of GOMAXPROCS")
+		})
+	})
+}
+
 func TestMain(m *testing.M) {
 	if err := os.Unsetenv(_maxProcsKey); err != nil {
 		log.Fatalf("Couldn't clear %s: %v\n", _maxProcsKey, err)
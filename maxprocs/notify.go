@@ -0,0 +1,192 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+var (
+	// _changes notifies OnChange subscribers of the GOMAXPROCS changes made
+	// by this package.
+	_changes changeNotifier
+
+	_setDefaultGOMAXPROCS = iruntime.SetDefaultGOMAXPROCS
+)
+
+// _historySize is the number of changes History keeps.
+const _historySize = 100
+
+// OnChange registers f to be called whenever this package changes
+// GOMAXPROCS, including from Set, the undo function it returns and the
+// re-evaluations of Watch, with the values before and after the change. It
+// returns a function that unregisters f.
+//
+// Changes are delivered in the order they happened, synchronously from the
+// goroutine that made the change, or that made an earlier change whose
+// delivery is still in progress. A Controller delivers its changes once it's
+// done, before its method returns. No locks are held while f runs, so it may
+// call Set, OnChange or the methods of a Controller, including the one that
+// made the change, whose changes are delivered after the current one.
+// Changes made directly with runtime.GOMAXPROCS aren't reported.
+func OnChange(f func(old, new int)) (cancel func()) {
+	return _changes.subscribe(f)
+}
+
+// Change is a change of GOMAXPROCS made by this package.
+type Change struct {
+	Time time.Time
+	Old  int
+	New  int
+}
+
+// History returns the most recent GOMAXPROCS changes made by this package,
+// oldest first, up to 100. These are the changes reported to OnChange.
+func History() []Change {
+	return _changes.recent()
+}
+
+type gomaxprocsChange struct {
+	old, new int
+}
+
+type changeSubscriber struct {
+	f func(old, new int)
+}
+
+type changeNotifier struct {
+	mu          sync.Mutex
+	subscribers []*changeSubscriber
+	pending     []gomaxprocsChange
+	history     []Change
+	// delivering is set while a goroutine delivers the pending changes.
+	delivering bool
+}
+
+func (n *changeNotifier) subscribe(f func(old, new int)) func() {
+	s := &changeSubscriber{f: f}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.subscribers = append(n.subscribers, s)
+
+	return func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		for i, other := range n.subscribers {
+			if other == s {
+				// Copy rather than modify in place, since deliver may be
+				// iterating over the previous slice.
+				subscribers := make([]*changeSubscriber, 0, len(n.subscribers)-1)
+				subscribers = append(subscribers, n.subscribers[:i]...)
+				n.subscribers = append(subscribers, n.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// set sets GOMAXPROCS to procs, or lets the Go runtime set it if procs is 0,
+// and notifies subscribers if it changed.
+func (n *changeNotifier) set(procs int) {
+	n.queue(procs)
+	n.deliver()
+}
+
+// queue sets GOMAXPROCS to procs, or lets the Go runtime set it if procs is
+// 0, and queues the change for deliver if it changed.
+func (n *changeNotifier) queue(procs int) {
+	n.change(func() int {
+		if procs == 0 {
+			prev := runtime.GOMAXPROCS(0)
+			_setDefaultGOMAXPROCS()
+			return prev
+		}
+		return runtime.GOMAXPROCS(procs)
+	})
+}
+
+// change calls f, which changes GOMAXPROCS and returns its previous value,
+// and queues the change for deliver if it changed.
+func (n *changeNotifier) change(f func() (prev int)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if prev, procs := f(), runtime.GOMAXPROCS(0); prev != procs {
+		n.pending = append(n.pending, gomaxprocsChange{old: prev, new: procs})
+		if len(n.history) == _historySize {
+			n.history = append(n.history[:0:0], n.history[1:]...)
+		}
+		n.history = append(n.history, Change{Time: time.Now(), Old: prev, New: procs})
+	}
+}
+
+func (n *changeNotifier) recent() []Change {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]Change(nil), n.history...)
+}
+
+// deliver notifies subscribers of the queued changes, unless another
+// goroutine is already delivering them.
+func (n *changeNotifier) deliver() {
+	n.mu.Lock()
+	if n.delivering {
+		// The delivering goroutine picks up the queued changes after its
+		// current one, which keeps changes in order.
+		n.mu.Unlock()
+		return
+	}
+
+	n.delivering = true
+	for len(n.pending) > 0 {
+		change := n.pending[0]
+		n.pending = n.pending[1:]
+		subscribers := n.subscribers
+
+		n.mu.Unlock()
+		n.notify(subscribers, change)
+		n.mu.Lock()
+	}
+	n.delivering = false
+	n.mu.Unlock()
+}
+
+// notify calls subscribers with change, without holding n.mu. If one of them
+// panics, the remaining changes are delivered by the next call to deliver.
+func (n *changeNotifier) notify(subscribers []*changeSubscriber, change gomaxprocsChange) {
+	completed := false
+	defer func() {
+		if !completed {
+			n.mu.Lock()
+			n.delivering = false
+			n.mu.Unlock()
+		}
+	}()
+
+	for _, s := range subscribers {
+		s.f(change.old, change.new)
+	}
+	completed = true
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type changeRecorder struct {
+	mu      sync.Mutex
+	changes [][2]int
+}
+
+func (r *changeRecorder) record(old, new int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, [2]int{old, new})
+}
+
+func (r *changeRecorder) get() [][2]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][2]int(nil), r.changes...)
+}
+
+func TestOnChange(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	var rec changeRecorder
+	cancel := OnChange(rec.record)
+	defer cancel()
+
+	opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return prev + 2, iruntime.CPUQuotaUsed, nil
+	})
+	undo, err := Set(opt)
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, [][2]int{{prev, prev + 2}}, rec.get(), "Set should notify before returning")
+
+	undo()
+	assert.Equal(t, [][2]int{{prev, prev + 2}, {prev + 2, prev}}, rec.get(), "undo should notify")
+
+	unchanged := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return prev, iruntime.CPUQuotaUsed, nil
+	})
+	undo, err = Set(unchanged)
+	require.NoError(t, err, "Set failed")
+	undo()
+	assert.Len(t, rec.get(), 2, "shouldn't notify if GOMAXPROCS didn't change")
+
+	cancel()
+	undo, err = Set(opt)
+	require.NoError(t, err, "Set failed")
+	undo()
+	assert.Len(t, rec.get(), 2, "shouldn't notify after cancel")
+}
+
+func TestHistory(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	opt := stubProcs(func(int, func(v float64) int) (int, iruntime.CPUQuotaStatus, error) {
+		return prev + 2, iruntime.CPUQuotaUsed, nil
+	})
+	undo, err := Set(opt)
+	require.NoError(t, err, "Set failed")
+	undo()
+
+	history := History()
+	require.True(t, len(history) >= 2, "should record Set and undo")
+	last := history[len(history)-2:]
+	assert.Equal(t, prev, last[0].Old)
+	assert.Equal(t, prev+2, last[0].New)
+	assert.Equal(t, prev+2, last[1].Old)
+	assert.Equal(t, prev, last[1].New)
+	assert.False(t, last[1].Time.Before(last[0].Time), "should be in order")
+
+	t.Run("Bounded", func(t *testing.T) {
+		var n changeNotifier
+		defer n.set(prev)
+		for i := 0; i < _historySize+10; i++ {
+			n.set(prev + 1 + i%2)
+		}
+		history := n.recent()
+		assert.Len(t, history, _historySize, "should only keep the most recent changes")
+		assert.Equal(t, prev+2, history[len(history)-1].New)
+	})
+}
+
+func TestOnChangeReentrant(t *testing.T) {
+	prev := currentMaxProcs()
+	defer _changes.set(prev)
+
+	var rec changeRecorder
+	cancelRec := OnChange(rec.record)
+	defer cancelRec()
+
+	// A subscriber that changes GOMAXPROCS itself must not deadlock, and its
+	// change is delivered after the current one to every subscriber.
+	cancel := OnChange(func(old, new int) {
+		if new == prev+1 {
+			_changes.set(prev + 2)
+		}
+	})
+	defer cancel()
+
+	_changes.set(prev + 1)
+	assert.Equal(t, [][2]int{{prev, prev + 1}, {prev + 1, prev + 2}}, rec.get())
+	assert.Equal(t, prev+2, currentMaxProcs())
+}
+
+func TestOnChangePanic(t *testing.T) {
+	prev := currentMaxProcs()
+	defer _changes.set(prev)
+
+	cancel := OnChange(func(old, new int) { panic("great sadness") })
+	assert.Panics(t, func() { _changes.set(prev + 1) })
+	cancel()
+
+	var rec changeRecorder
+	defer OnChange(rec.record)()
+	_changes.set(prev + 2)
+	assert.Equal(t, [][2]int{{prev + 1, prev + 2}}, rec.get(), "should deliver changes after a panic")
+}
+
+func TestOnChangeConcurrent(t *testing.T) {
+	prev := currentMaxProcs()
+	defer _changes.set(prev)
+
+	var rec changeRecorder
+	defer OnChange(rec.record)()
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(procs int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_changes.set(procs)
+				runtime.Gosched()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Changes are delivered in order, so each starts where the last ended.
+	changes := rec.get()
+	require.NotEmpty(t, changes)
+	assert.Equal(t, prev, changes[0][0])
+	for i := 1; i < len(changes); i++ {
+		assert.Equal(t, changes[i-1][1], changes[i][0], "change %v doesn't follow the previous one", i)
+	}
+	assert.Equal(t, currentMaxProcs(), changes[len(changes)-1][1])
+}
+
+func TestOnChangeCallsController(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	c := NewController((&stubQuota{quota: float64(prev + 1)}).option())
+	defer c.Close()
+
+	// A subscriber may call the Controller that made the change, which is
+	// done with it by then.
+	var (
+		decision    Decision
+		overrideErr error
+	)
+	cancel := OnChange(func(old, new int) {
+		if new == prev+1 {
+			decision = c.Decision()
+			overrideErr = c.Override(prev+2, 0)
+		}
+	})
+	defer cancel()
+
+	require.NoError(t, c.Apply(), "Apply failed")
+	require.NoError(t, overrideErr, "Override from a subscriber failed")
+	assert.Equal(t, QuotaUsed, decision.Status, "should see the decision of Apply")
+	assert.Equal(t, prev+2, currentMaxProcs(), "should override before Apply returns")
+}
@@ -0,0 +1,430 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+// _policyFileKey is the environment variable holding the path of the policy
+// file used without the PolicyFile option.
+const _policyFileKey = "AUTOMAXPROCS_CONFIG"
+
+// Policy is an ordered list of rules that adjust how Set derives GOMAXPROCS
+// from the facts it detects about the process, such as its CPU quota. It's
+// loaded from a JSON file like:
+//
+//	{
+//	  "rules": [
+//	    {"name": "big hosts", "when": {"hostCPUs": {"gt": 64}, "quota": {"defined": false}}, "max": 32},
+//	    {"when": {"quota": {"gte": 4}}, "rounding": "ceil"},
+//	    {"when": {"env": {"SIDECAR": "true"}}, "reserveCPUs": 0.5}
+//	  ]
+//	}
+//
+// Set evaluates the rules in order. Each rule whose conditions all hold
+// applies: the settings it has override those of Set's options and of the
+// rules before it.
+type Policy struct {
+	Rules []PolicyRule `json:"rules"`
+}
+
+// PolicyRule is a rule of a Policy. Its settings other than Max behave like
+// the options of the same names.
+type PolicyRule struct {
+	// Name identifies the rule in logs and in Decision.Rules. It defaults
+	// to the position of the rule, such as "rules[0]".
+	Name string `json:"name,omitempty"`
+	// When holds the conditions under which the rule applies. A rule
+	// without conditions always applies.
+	When PolicyConditions `json:"when"`
+
+	Min             *int     `json:"min,omitempty"`
+	Rounding        string   `json:"rounding,omitempty"`
+	ReserveCPUs     *float64 `json:"reserveCPUs,omitempty"`
+	ReserveFraction *float64 `json:"reserveFraction,omitempty"`
+	// Max is the maximum GOMAXPROCS. Unlike the CPU quota, it also applies if
+	// no quota is defined, lowering GOMAXPROCS from the number of CPUs. Min
+	// takes precedence over it.
+	Max *int `json:"max,omitempty"`
+
+	rounding RoundingStrategy
+}
+
+// PolicyConditions are the conditions of a PolicyRule, which all must hold
+// for it to apply.
+type PolicyConditions struct {
+	// Quota is the CPU quota, in CPUs.
+	Quota *PolicyRange `json:"quota,omitempty"`
+	// CPUSetSize is the number of CPUs in the cpuset of the cgroup.
+	CPUSetSize *PolicyRange `json:"cpusetSize,omitempty"`
+	// HostCPUs is the number of online CPUs of the host, regardless of the
+	// cpuset of the process. It's always defined.
+	HostCPUs *PolicyRange `json:"hostCPUs,omitempty"`
+	// CGroupVersion is 1 or 2, or 0 outside of cgroups. It's always defined.
+	CGroupVersion *PolicyRange `json:"cgroupVersion,omitempty"`
+	// MemoryLimit is the memory limit of the cgroup, in bytes.
+	MemoryLimit *PolicyRange `json:"memoryLimit,omitempty"`
+	// Env holds environment variables and the values they must have, such
+	// as pod labels exposed through the Kubernetes downward API.
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// PolicyRange is a condition on a detected fact. It holds if the fact is
+// defined as required by Defined, and within all the bounds that are set.
+// Bounds imply that the fact is defined.
+type PolicyRange struct {
+	Defined *bool    `json:"defined,omitempty"`
+	Eq      *float64 `json:"eq,omitempty"`
+	GT      *float64 `json:"gt,omitempty"`
+	GTE     *float64 `json:"gte,omitempty"`
+	LT      *float64 `json:"lt,omitempty"`
+	LTE     *float64 `json:"lte,omitempty"`
+}
+
+// PolicyError is returned by Set, ParsePolicy and LoadPolicy if a policy
+// can't be read or is invalid.
+type PolicyError struct {
+	// File is the path of the policy file, if any.
+	File string
+	// Line and Column locate the error in the file, if known, starting at 1.
+	Line, Column int
+	// Field is the path of the invalid field, such as "rules[1].max", if
+	// known.
+	Field string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	var b strings.Builder
+	b.WriteString("maxprocs: invalid policy")
+	if e.File != "" {
+		fmt.Fprintf(&b, " %v", e.File)
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%v:%v", e.Line, e.Column)
+	}
+	b.WriteString(": ")
+	if e.Field != "" {
+		fmt.Fprintf(&b, "%v: ", e.Field)
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyFile makes Set load a Policy from the JSON file at path whenever it
+// evaluates the CPU quota. By default, Set loads the file at the path in the
+// AUTOMAXPROCS_CONFIG environment variable, if set. An empty path disables
+// policies.
+//
+// If the file doesn't exist, Set logs it, reports it in Decision.Warnings and
+// goes on without a policy, unless in strict mode. If the file can't be
+// loaded otherwise, Set leaves GOMAXPROCS unchanged and returns a
+// *PolicyError.
+func PolicyFile(path string) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.policyFile = path
+	})
+}
+
+// LoadPolicy loads and validates the Policy in the JSON file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &PolicyError{File: path, Err: err}
+	}
+	p, err := ParsePolicy(data)
+	if err != nil {
+		var policyErr *PolicyError
+		if errors.As(err, &policyErr) {
+			policyErr.File = path
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParsePolicy parses and validates a Policy in JSON. Unknown fields are
+// errors.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, decodeError(data, err)
+	}
+	if dec.More() {
+		rest := data[dec.InputOffset():]
+		line, col := position(data, int64(len(data)-len(bytes.TrimLeft(rest, " \t\r\n"))))
+		return nil, &PolicyError{Line: line, Column: col, Err: errors.New("unexpected data after the policy")}
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// decodeError converts an error decoding data to a *PolicyError.
+func decodeError(data []byte, err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		// The offset is just past the offending byte.
+		line, col := position(data, syntaxErr.Offset-1)
+		return &PolicyError{Line: line, Column: col, Err: err}
+	case errors.As(err, &typeErr):
+		// The offset is just past the offending value.
+		line, col := position(data, typeErr.Offset-1)
+		return &PolicyError{
+			Line:   line,
+			Column: col,
+			Field:  typeErr.Field,
+			Err:    fmt.Errorf("cannot use %v as %v", typeErr.Value, typeErr.Type),
+		}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		line, col := position(data, int64(len(data)))
+		return &PolicyError{Line: line, Column: col, Err: err}
+	default:
+		return &PolicyError{Err: errors.New(strings.TrimPrefix(err.Error(), "json: "))}
+	}
+}
+
+// position returns the line and column of the byte at offset in data, both
+// starting at 1.
+func position(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// validate checks the settings of p, and parses the rounding strategies of
+// its rules.
+func (p *Policy) validate() error {
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		field := "rules[" + strconv.Itoa(i) + "]"
+		invalid := func(name, format string, args ...interface{}) error {
+			return &PolicyError{Field: field + "." + name, Err: fmt.Errorf(format, args...)}
+		}
+
+		if err := r.When.validate(field + ".when"); err != nil {
+			return err
+		}
+		if r.Min != nil && *r.Min < 1 {
+			return invalid("min", "must be at least 1, got %v", *r.Min)
+		}
+		if r.Max != nil && *r.Max < 1 {
+			return invalid("max", "must be at least 1, got %v", *r.Max)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return invalid("min", "must not exceed max %v, got %v", *r.Max, *r.Min)
+		}
+		if r.Rounding != "" {
+			rounding, err := ParseRounding(r.Rounding)
+			if err != nil {
+				return &PolicyError{Field: field + ".rounding", Err: err}
+			}
+			r.rounding = rounding
+		}
+		if r.ReserveCPUs != nil && *r.ReserveCPUs < 0 {
+			return invalid("reserveCPUs", "must not be negative, got %v", *r.ReserveCPUs)
+		}
+		if r.ReserveFraction != nil && (*r.ReserveFraction < 0 || *r.ReserveFraction > 1) {
+			return invalid("reserveFraction", "must be between 0 and 1, got %v", *r.ReserveFraction)
+		}
+		if r.Min == nil && r.Max == nil && r.Rounding == "" && r.ReserveCPUs == nil && r.ReserveFraction == nil {
+			return &PolicyError{Field: field, Err: errors.New("rule has no settings")}
+		}
+	}
+	return nil
+}
+
+func (c *PolicyConditions) validate(field string) error {
+	ranges := []struct {
+		name string
+		r    *PolicyRange
+	}{
+		{"quota", c.Quota},
+		{"cpusetSize", c.CPUSetSize},
+		{"hostCPUs", c.HostCPUs},
+		{"cgroupVersion", c.CGroupVersion},
+		{"memoryLimit", c.MemoryLimit},
+	}
+	for _, f := range ranges {
+		if f.r == nil {
+			continue
+		}
+		if err := f.r.validate(); err != nil {
+			return &PolicyError{Field: field + "." + f.name, Err: err}
+		}
+	}
+	for name := range c.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return &PolicyError{Field: field + ".env", Err: fmt.Errorf("invalid environment variable name %q", name)}
+		}
+	}
+	return nil
+}
+
+func (r *PolicyRange) validate() error {
+	lower, upper := math.Inf(-1), math.Inf(1)
+	lowerIncl, upperIncl := true, true
+	bounded := false
+	if r.Eq != nil {
+		lower, upper, bounded = *r.Eq, *r.Eq, true
+	}
+	if r.GT != nil && *r.GT >= lower {
+		lower, lowerIncl, bounded = *r.GT, false, true
+	}
+	if r.GTE != nil && *r.GTE > lower {
+		lower, lowerIncl, bounded = *r.GTE, true, true
+	}
+	if r.LT != nil && *r.LT <= upper {
+		upper, upperIncl, bounded = *r.LT, false, true
+	}
+	if r.LTE != nil && *r.LTE < upper {
+		upper, upperIncl, bounded = *r.LTE, true, true
+	}
+	if r.Defined != nil && !*r.Defined && bounded {
+		return errors.New("bounds require defined to be true")
+	}
+	if lower > upper || lower == upper && !(lowerIncl && upperIncl) {
+		return errors.New("no value is within the bounds")
+	}
+	return nil
+}
+
+// holds reports whether the fact v, if defined, satisfies r.
+func (r *PolicyRange) holds(v float64, defined bool) bool {
+	if r == nil {
+		return true
+	}
+	if r.Defined != nil && *r.Defined != defined {
+		return false
+	}
+	if !defined {
+		return r.Eq == nil && r.GT == nil && r.GTE == nil && r.LT == nil && r.LTE == nil
+	}
+	return (r.Eq == nil || v == *r.Eq) &&
+		(r.GT == nil || v > *r.GT) &&
+		(r.GTE == nil || v >= *r.GTE) &&
+		(r.LT == nil || v < *r.LT) &&
+		(r.LTE == nil || v <= *r.LTE)
+}
+
+// holds reports whether the conditions hold for facts.
+func (c *PolicyConditions) holds(facts iruntime.Facts) bool {
+	for name, want := range c.Env {
+		if v, ok := os.LookupEnv(name); !ok || v != want {
+			return false
+		}
+	}
+	return c.Quota.holds(facts.Quota, facts.Quota >= 0) &&
+		c.CPUSetSize.holds(float64(facts.CPUSetSize), facts.CPUSetSize >= 0) &&
+		c.HostCPUs.holds(float64(facts.HostCPUs), true) &&
+		c.CGroupVersion.holds(float64(facts.CGroupVersion), true) &&
+		c.MemoryLimit.holds(float64(facts.MemoryLimit), facts.MemoryLimit >= 0)
+}
+
+// applyPolicy loads the policy file, if any, and applies it to out with the
+// facts detected about the process.
+func (c *config) applyPolicy(out *policyOutcome) error {
+	if c.policyFile == "" {
+		return nil
+	}
+	p, err := LoadPolicy(c.policyFile)
+	if errors.Is(err, os.ErrNotExist) && !c.strict {
+		out.warnings = append(out.warnings, "missing policy file "+c.policyFile)
+		c.log("maxprocs: Ignoring missing policy file %v", c.policyFile)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	facts, err := c.detectFacts(out.cfg.Source, out.cfg.OnInvalidLine)
+	if err != nil {
+		return err
+	}
+	p.apply(facts, out)
+	return nil
+}
+
+// policyOutcome is the configuration of a detection after applying a Policy.
+type policyOutcome struct {
+	cfg      iruntime.Config
+	rounding string
+	rules    []string
+	warnings []string
+}
+
+// apply applies the rules of p that hold for facts to out.
+func (p *Policy) apply(facts iruntime.Facts, out *policyOutcome) {
+	for i, r := range p.Rules {
+		if !r.When.holds(facts) {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = "rules[" + strconv.Itoa(i) + "]"
+		}
+		out.rules = append(out.rules, name)
+
+		if r.Min != nil {
+			out.cfg.Min = *r.Min
+		}
+		if r.Max != nil {
+			out.cfg.Max = *r.Max
+		}
+		if r.Rounding != "" {
+			out.cfg.Round = r.rounding.Round
+			out.rounding = r.rounding.String()
+		}
+		if r.ReserveCPUs != nil {
+			out.cfg.ReserveCPUs = *r.ReserveCPUs
+		}
+		if r.ReserveFraction != nil {
+			out.cfg.ReserveFraction = *r.ReserveFraction
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+const _testPolicy = `{
+  "rules": [
+    {"name": "big hosts", "when": {"hostCPUs": {"gt": 64}, "quota": {"defined": false}}, "max": 32},
+    {"when": {"quota": {"gte": 4}}, "rounding": "ceil"},
+    {"when": {"env": {"MAXPROCS_TEST_SIDECAR": "true"}}, "reserveCPUs": 0.5},
+    {"when": {"cgroupVersion": {"eq": 1}, "memoryLimit": {"lt": 1073741824}}, "min": 2, "reserveFraction": 0.25}
+  ]
+}`
+
+func writePolicy(t *testing.T, policy string) string {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(path, []byte(policy), 0o644))
+	return path
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		p, err := ParsePolicy([]byte(_testPolicy))
+		require.NoError(t, err)
+		require.Len(t, p.Rules, 4)
+		assert.Equal(t, "big hosts", p.Rules[0].Name)
+		assert.Equal(t, 32, *p.Rules[0].Max)
+		assert.Equal(t, "ceil", p.Rules[1].rounding.String())
+	})
+
+	tests := []struct {
+		name    string
+		give    string
+		wantErr string
+	}{
+		{
+			name:    "Syntax",
+			give:    "{\n  \"rules\": [\n    {\"max\": 2,}\n  ]\n}",
+			wantErr: `maxprocs: invalid policy:3:15: invalid character '}' looking for beginning of object key string`,
+		},
+		{
+			name:    "Truncated",
+			give:    "{\n  \"rules\": [",
+			wantErr: `maxprocs: invalid policy:2:13: unexpected EOF`,
+		},
+		{
+			name:    "Type",
+			give:    "{\n  \"rules\": [{\"max\": \"32\"}]\n}",
+			wantErr: `max: cannot use string as int`,
+		},
+		{
+			name:    "UnknownField",
+			give:    `{"rules": [{"maximum": 32}]}`,
+			wantErr: `maxprocs: invalid policy: unknown field "maximum"`,
+		},
+		{
+			name:    "TrailingData",
+			give:    `{"rules": []} {}`,
+			wantErr: `maxprocs: invalid policy:1:15: unexpected data after the policy`,
+		},
+		{
+			name:    "NoSettings",
+			give:    `{"rules": [{"max": 2}, {"when": {"quota": {"gt": 1}}}]}`,
+			wantErr: `maxprocs: invalid policy: rules[1]: rule has no settings`,
+		},
+		{
+			name:    "Min",
+			give:    `{"rules": [{"min": 0}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].min: must be at least 1, got 0`,
+		},
+		{
+			name:    "Max",
+			give:    `{"rules": [{"max": -1}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].max: must be at least 1, got -1`,
+		},
+		{
+			name:    "MinAboveMax",
+			give:    `{"rules": [{"min": 4, "max": 2}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].min: must not exceed max 2, got 4`,
+		},
+		{
+			name:    "Rounding",
+			give:    `{"rules": [{"rounding": "up"}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].rounding: invalid rounding strategy "up"`,
+		},
+		{
+			name:    "ReserveCPUs",
+			give:    `{"rules": [{"reserveCPUs": -0.5}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].reserveCPUs: must not be negative, got -0.5`,
+		},
+		{
+			name:    "ReserveFraction",
+			give:    `{"rules": [{"reserveFraction": 1.5}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].reserveFraction: must be between 0 and 1, got 1.5`,
+		},
+		{
+			name:    "EmptyRange",
+			give:    `{"rules": [{"when": {"quota": {"gt": 4, "lt": 2}}, "max": 2}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].when.quota: no value is within the bounds`,
+		},
+		{
+			name:    "EmptyExclusiveRange",
+			give:    `{"rules": [{"when": {"hostCPUs": {"gte": 4, "lt": 4}}, "max": 2}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].when.hostCPUs: no value is within the bounds`,
+		},
+		{
+			name:    "UndefinedWithBounds",
+			give:    `{"rules": [{"when": {"memoryLimit": {"defined": false, "gt": 0}}, "max": 2}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].when.memoryLimit: bounds require defined to be true`,
+		},
+		{
+			name:    "EnvName",
+			give:    `{"rules": [{"when": {"env": {"A=B": "C"}}, "max": 2}]}`,
+			wantErr: `maxprocs: invalid policy: rules[0].when.env: invalid environment variable name "A=B"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.give))
+			require.Error(t, err)
+			var policyErr *PolicyError
+			require.True(t, errors.As(err, &policyErr), "expected a *PolicyError, got %T", err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	t.Run("Missing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.json")
+		_, err := LoadPolicy(path)
+		var policyErr *PolicyError
+		require.True(t, errors.As(err, &policyErr), "expected a *PolicyError, got %T", err)
+		assert.Equal(t, path, policyErr.File)
+		assert.True(t, errors.Is(err, os.ErrNotExist), "should wrap the read error")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		path := writePolicy(t, "{\"rules\": [{\"max\": 0}]}")
+		_, err := LoadPolicy(path)
+		require.Error(t, err)
+		assert.Equal(t, "maxprocs: invalid policy "+path+": rules[0].max: must be at least 1, got 0", err.Error())
+	})
+}
+
+func TestPolicyApply(t *testing.T) {
+	p, err := ParsePolicy([]byte(_testPolicy))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		facts     iruntime.Facts
+		sidecar   bool
+		wantRules []string
+		wantCfg   iruntime.Config
+		wantRound string
+	}{
+		{
+			name:      "BigHostWithoutQuota",
+			facts:     iruntime.Facts{Quota: -1, CPUSetSize: -1, HostCPUs: 96, CGroupVersion: 2, MemoryLimit: -1},
+			wantRules: []string{"big hosts"},
+			wantCfg:   iruntime.Config{Min: 1, Max: 32},
+		},
+		{
+			name:    "BigHostWithQuota",
+			facts:   iruntime.Facts{Quota: 2, CPUSetSize: -1, HostCPUs: 96, CGroupVersion: 2, MemoryLimit: -1},
+			wantCfg: iruntime.Config{Min: 1},
+		},
+		{
+			name:      "LargeQuota",
+			facts:     iruntime.Facts{Quota: 4, CPUSetSize: -1, HostCPUs: 8, CGroupVersion: 2, MemoryLimit: -1},
+			wantRules: []string{"rules[1]"},
+			wantCfg:   iruntime.Config{Min: 1},
+			wantRound: "ceil",
+		},
+		{
+			name:      "Sidecar",
+			facts:     iruntime.Facts{Quota: 4, CPUSetSize: -1, HostCPUs: 8, CGroupVersion: 2, MemoryLimit: -1},
+			sidecar:   true,
+			wantRules: []string{"rules[1]", "rules[2]"},
+			wantCfg:   iruntime.Config{Min: 1, ReserveCPUs: 0.5},
+			wantRound: "ceil",
+		},
+		{
+			name:      "SmallMemoryV1",
+			facts:     iruntime.Facts{Quota: 1, CPUSetSize: 2, HostCPUs: 8, CGroupVersion: 1, MemoryLimit: 512 << 20},
+			wantRules: []string{"rules[3]"},
+			wantCfg:   iruntime.Config{Min: 2, ReserveFraction: 0.25},
+		},
+		{
+			name:    "NoMemoryLimitV1",
+			facts:   iruntime.Facts{Quota: 1, CPUSetSize: 2, HostCPUs: 8, CGroupVersion: 1, MemoryLimit: -1},
+			wantCfg: iruntime.Config{Min: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sidecar {
+				t.Setenv("MAXPROCS_TEST_SIDECAR", "true")
+			}
+			out := policyOutcome{cfg: iruntime.Config{Min: 1}}
+			p.apply(tt.facts, &out)
+			assert.Equal(t, tt.wantRules, out.rules)
+			assert.Equal(t, tt.wantRound, out.rounding)
+			if tt.wantRound != "" {
+				assert.Equal(t, 3, out.cfg.Round(2.1), "should round with the rule's strategy")
+				out.cfg.Round = nil
+			}
+			assert.Equal(t, tt.wantCfg, out.cfg)
+		})
+	}
+}
+
+func TestPolicyFile(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	var got iruntime.Config
+	opt := optionFunc(func(cfg *config) {
+		cfg.detectFacts = func(iruntime.QuotaSource, func(error)) (iruntime.Facts, error) {
+			return iruntime.Facts{Quota: 4.2, CPUSetSize: -1, HostCPUs: 8, CGroupVersion: 2, MemoryLimit: -1}, nil
+		}
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			got = c
+			return iruntime.Result{GOMAXPROCS: c.Round(4.2), Status: iruntime.CPUQuotaUsed, Quota: 4.2}, nil
+		}
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		var d Decision
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt, Report(&d), PolicyFile(writePolicy(t, _testPolicy)))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 5, currentMaxProcs())
+		assert.Equal(t, []string{"rules[1]"}, d.Rules)
+		assert.Contains(t, buf.String(), `maxprocs: Applying policy rule "rules[1]"`)
+		assert.Contains(t, buf.String(), "maxprocs: Updating GOMAXPROCS=5: determined from CPU quota with ceil rounding")
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		t.Setenv(_policyFileKey, writePolicy(t, `{"rules": [{"max": 3}]}`))
+		var d Decision
+		undo, err := Set(opt, Report(&d))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, got.Max, "max should be passed through")
+		assert.Equal(t, []string{"rules[0]"}, d.Rules)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Setenv(_policyFileKey, writePolicy(t, `{"rules": [{"max": 3}]}`))
+		var d Decision
+		undo, err := Set(opt, Report(&d), PolicyFile(""))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Zero(t, got.Max, "policy should be disabled")
+		assert.Empty(t, d.Rules)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		path := writePolicy(t, `{"rules": [{"max": 0}]}`)
+		undo, err := Set(opt, PolicyFile(path))
+		defer undo()
+		var policyErr *PolicyError
+		require.True(t, errors.As(err, &policyErr), "expected a *PolicyError, got %v", err)
+		assert.Equal(t, "rules[0].max", policyErr.Field)
+		assert.Equal(t, prev, currentMaxProcs(), "GOMAXPROCS should be unchanged")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.json")
+		var d Decision
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt, Report(&d), PolicyFile(path))
+		defer undo()
+		require.NoError(t, err, "a missing policy file should be ignored")
+		assert.Equal(t, 4, currentMaxProcs(), "should use the CPU quota without a policy")
+		assert.Empty(t, d.Rules)
+		assert.Equal(t, []string{"missing policy file " + path}, d.Warnings)
+		assert.Contains(t, buf.String(), "maxprocs: Ignoring missing policy file "+path)
+	})
+
+	t.Run("MissingStrict", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "missing.json")
+		undo, err := Set(opt, Strict(), PolicyFile(path))
+		defer undo()
+		var policyErr *PolicyError
+		require.True(t, errors.As(err, &policyErr), "expected a *PolicyError, got %v", err)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Equal(t, prev, currentMaxProcs(), "GOMAXPROCS should be unchanged")
+	})
+
+	t.Run("FactsError", func(t *testing.T) {
+		giveErr := errors.New("great sadness")
+		factsErr := optionFunc(func(cfg *config) {
+			cfg.detectFacts = func(iruntime.QuotaSource, func(error)) (iruntime.Facts, error) {
+				return iruntime.Facts{}, giveErr
+			}
+		})
+		undo, err := Set(opt, factsErr, Strict(), PolicyFile(writePolicy(t, _testPolicy)))
+		defer undo()
+		var detectionErr *DetectionError
+		require.True(t, errors.As(err, &detectionErr), "expected a *DetectionError, got %v", err)
+		assert.ErrorIs(t, err, giveErr)
+	})
+}
+
+func TestPolicyMax(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	var d Decision
+	buf, logOpt := testLogger()
+	opt := optionFunc(func(cfg *config) {
+		cfg.detectFacts = func(iruntime.QuotaSource, func(error)) (iruntime.Facts, error) {
+			return iruntime.Facts{Quota: -1, CPUSetSize: -1, HostCPUs: 8, MemoryLimit: -1}, nil
+		}
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			assert.Equal(t, 4, c.Max, "max should be passed through")
+			return iruntime.Result{GOMAXPROCS: 4, Status: iruntime.CPUQuotaMaxUsed, Quota: -1}, nil
+		}
+	})
+	undo, err := Set(logOpt, opt, PolicyFile(writePolicy(t, `{"rules": [{"max": 4}]}`)), Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 4, currentMaxProcs())
+	assert.Equal(t, MaxUsed, d.Status)
+	assert.Zero(t, d.Quota, "quota should be undefined")
+	assert.Contains(t, buf.String(), "maxprocs: Updating GOMAXPROCS=4: using maximum allowed GOMAXPROCS")
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RoundingStrategy converts the CPU quota, in CPUs, to GOMAXPROCS. Unlike a
+// function passed to RoundQuotaFunc, it has a name, which Set logs, and it can
+// be parsed from a string with ParseRounding. The zero value rounds down, like
+// RoundFloor.
+type RoundingStrategy struct {
+	name  string
+	round func(float64) int
+}
+
+// RoundFloor rounds the CPU quota down. This is what Set does by default.
+func RoundFloor() RoundingStrategy {
+	return RoundingStrategy{name: "floor", round: floor}
+}
+
+// RoundCeil rounds the CPU quota up.
+func RoundCeil() RoundingStrategy {
+	return RoundingStrategy{name: "ceil", round: func(v float64) int {
+		return int(math.Ceil(v))
+	}}
+}
+
+// RoundNearest rounds the CPU quota to the nearest integer, rounding half
+// away from zero.
+func RoundNearest() RoundingStrategy {
+	return RoundingStrategy{name: "nearest", round: func(v float64) int {
+		return int(math.Round(v))
+	}}
+}
+
+// _fractionEpsilon absorbs the floating-point error of the fractional part
+// of a quota, such as 3.3 - 3, which is slightly below 0.3.
+const _fractionEpsilon = 1e-9
+
+// RoundCeilAbove rounds the CPU quota up if its fractional part is at least
+// threshold, and down otherwise. For example, with a threshold of 0.75, a
+// quota of 2.75 gives 3 and a quota of 2.5 gives 2.
+func RoundCeilAbove(threshold float64) RoundingStrategy {
+	return RoundingStrategy{
+		name: "ceil-above:" + formatFloat(threshold),
+		round: func(v float64) int {
+			whole := math.Floor(v)
+			if v-whole >= threshold-_fractionEpsilon {
+				return int(whole) + 1
+			}
+			return int(whole)
+		},
+	}
+}
+
+// RoundScaled multiplies the CPU quota by factor, and rounds the result with
+// base. For example, RoundScaled(1.5, RoundFloor()) sets GOMAXPROCS to 3 for
+// a quota of 2 CPUs.
+func RoundScaled(factor float64, base RoundingStrategy) RoundingStrategy {
+	name := "scale:" + formatFloat(factor)
+	if base.round != nil && base.name != "floor" {
+		name += ":" + base.name
+	}
+	return RoundingStrategy{name: name, round: func(v float64) int {
+		return base.Round(v * factor)
+	}}
+}
+
+var _roundingStrategies = map[string]func() RoundingStrategy{
+	"floor":   RoundFloor,
+	"ceil":    RoundCeil,
+	"nearest": RoundNearest,
+}
+
+// ParseRounding returns the RoundingStrategy named by s, which is one of
+//
+//	floor
+//	ceil
+//	nearest
+//	ceil-above:<threshold>  (see RoundCeilAbove; 0 < threshold <= 1)
+//	scale:<factor>[:<strategy>]  (see RoundScaled; factor > 0, strategy defaults to floor)
+//
+// The String method of the result returns s in this form.
+func ParseRounding(s string) (RoundingStrategy, error) {
+	name, arg, hasArg := strings.Cut(s, ":")
+	switch name {
+	case "floor", "ceil", "nearest":
+		if hasArg {
+			return RoundingStrategy{}, fmt.Errorf("invalid rounding strategy %q: %v takes no argument", s, name)
+		}
+		return _roundingStrategies[name](), nil
+	case "ceil-above":
+		threshold, err := strconv.ParseFloat(arg, 64)
+		if err != nil || !(threshold > 0 && threshold <= 1) {
+			return RoundingStrategy{}, fmt.Errorf("invalid rounding strategy %q: threshold must be a number in (0, 1]", s)
+		}
+		return RoundCeilAbove(threshold), nil
+	case "scale":
+		factorArg, baseArg, hasBase := strings.Cut(arg, ":")
+		factor, err := strconv.ParseFloat(factorArg, 64)
+		if err != nil || !(factor > 0) || math.IsInf(factor, 1) {
+			return RoundingStrategy{}, fmt.Errorf("invalid rounding strategy %q: factor must be a positive number", s)
+		}
+		base := RoundFloor()
+		if hasBase {
+			if base, err = ParseRounding(baseArg); err != nil {
+				return RoundingStrategy{}, fmt.Errorf("invalid rounding strategy %q: %w", s, err)
+			}
+		}
+		return RoundScaled(factor, base), nil
+	default:
+		return RoundingStrategy{}, fmt.Errorf("invalid rounding strategy %q: must be floor, ceil, nearest, ceil-above:<threshold> or scale:<factor>", s)
+	}
+}
+
+// Round converts the CPU quota v to GOMAXPROCS.
+func (s RoundingStrategy) Round(v float64) int {
+	if s.round == nil {
+		return floor(v)
+	}
+	return s.round(v)
+}
+
+// String returns the name of the strategy, which ParseRounding accepts.
+func (s RoundingStrategy) String() string {
+	if s.round == nil {
+		return "floor"
+	}
+	return s.name
+}
+
+// Rounding sets the strategy used to convert the CPU quota to GOMAXPROCS, and
+// makes Set log its name. It replaces RoundQuotaFunc.
+func Rounding(s RoundingStrategy) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.roundQuotaFunc = s.Round
+		cfg.rounding = s.String()
+	})
+}
+
+func floor(v float64) int {
+	return int(math.Floor(v))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
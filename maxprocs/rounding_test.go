@@ -0,0 +1,181 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+func TestParseRounding(t *testing.T) {
+	tests := []struct {
+		give    string
+		quotas  map[float64]int
+		wantErr string
+	}{
+		{
+			give:   "floor",
+			quotas: map[float64]int{0.5: 0, 2: 2, 2.99: 2},
+		},
+		{
+			give:   "ceil",
+			quotas: map[float64]int{0.5: 1, 2: 2, 2.01: 3},
+		},
+		{
+			give:   "nearest",
+			quotas: map[float64]int{0.4: 0, 2.5: 3, 2.49: 2},
+		},
+		{
+			give:   "ceil-above:0.75",
+			quotas: map[float64]int{0.5: 0, 2: 2, 2.74: 2, 2.75: 3, 2.9: 3},
+		},
+		{
+			give:   "ceil-above:0.3",
+			quotas: map[float64]int{3.29: 3, 3.3: 4, 7.3: 8},
+		},
+		{
+			give:   "ceil-above:1",
+			quotas: map[float64]int{2: 2, 2.99: 2},
+		},
+		{
+			give:   "scale:1.5",
+			quotas: map[float64]int{1: 1, 2: 3, 2.5: 3},
+		},
+		{
+			give:   "scale:1.5:ceil",
+			quotas: map[float64]int{1: 2, 2: 3, 2.5: 4},
+		},
+		{
+			give:   "scale:0.5:ceil-above:0.5",
+			quotas: map[float64]int{2: 1, 3: 2, 2.9: 1},
+		},
+		{
+			give:    "",
+			wantErr: `invalid rounding strategy "": must be floor, ceil, nearest`,
+		},
+		{
+			give:    "round",
+			wantErr: `invalid rounding strategy "round": must be floor, ceil, nearest`,
+		},
+		{
+			give:    "ceil:1",
+			wantErr: `invalid rounding strategy "ceil:1": ceil takes no argument`,
+		},
+		{
+			give:    "ceil-above",
+			wantErr: `invalid rounding strategy "ceil-above": threshold must be a number in (0, 1]`,
+		},
+		{
+			give:    "ceil-above:0",
+			wantErr: `invalid rounding strategy "ceil-above:0": threshold must be a number in (0, 1]`,
+		},
+		{
+			give:    "ceil-above:1.5",
+			wantErr: `invalid rounding strategy "ceil-above:1.5": threshold must be a number in (0, 1]`,
+		},
+		{
+			give:    "scale:-1",
+			wantErr: `invalid rounding strategy "scale:-1": factor must be a positive number`,
+		},
+		{
+			give:    "scale:NaN",
+			wantErr: `invalid rounding strategy "scale:NaN": factor must be a positive number`,
+		},
+		{
+			give:    "scale:2:up",
+			wantErr: `invalid rounding strategy "scale:2:up": invalid rounding strategy "up"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.give, func(t *testing.T) {
+			s, err := ParseRounding(tt.give)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.give, s.String(), "name should round-trip")
+			for quota, want := range tt.quotas {
+				assert.Equal(t, want, s.Round(quota), "rounding %v", quota)
+			}
+		})
+	}
+}
+
+func TestRoundingStrategy(t *testing.T) {
+	t.Run("Zero", func(t *testing.T) {
+		var s RoundingStrategy
+		assert.Equal(t, "floor", s.String())
+		assert.Equal(t, 2, s.Round(2.9))
+	})
+
+	t.Run("Names", func(t *testing.T) {
+		assert.Equal(t, "ceil-above:0.75", RoundCeilAbove(0.75).String())
+		assert.Equal(t, "scale:1.5", RoundScaled(1.5, RoundFloor()).String())
+		assert.Equal(t, "scale:1.5", RoundScaled(1.5, RoundingStrategy{}).String())
+		assert.Equal(t, "scale:2:nearest", RoundScaled(2, RoundNearest()).String())
+	})
+}
+
+func TestRounding(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	opt := optionFunc(func(cfg *config) {
+		cfg.procs = func(c iruntime.Config) (iruntime.Result, error) {
+			return iruntime.Result{GOMAXPROCS: c.Round(2.8), Status: iruntime.CPUQuotaUsed, Quota: 2.8}, nil
+		}
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt, Rounding(RoundCeilAbove(0.75)))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, currentMaxProcs())
+		assert.Contains(t, buf.String(), "maxprocs: Updating GOMAXPROCS=3: determined from CPU quota with ceil-above:0.75 rounding")
+	})
+
+	t.Run("RoundQuotaFuncReplaces", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt, Rounding(RoundCeil()), RoundQuotaFunc(func(v float64) int { return 2 }))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 2, currentMaxProcs())
+		assert.Equal(t, "maxprocs: Updating GOMAXPROCS=2: determined from CPU quota", buf.String())
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, opt)
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 2, currentMaxProcs())
+		assert.NotContains(t, buf.String(), "rounding")
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"os"
+	"os/signal"
+)
+
+var (
+	_signalNotify = signal.Notify
+	_signalStop   = signal.Stop
+)
+
+// ReevaluateOnSignal makes Set re-evaluate the CPU quota whenever the process
+// receives sig, such as syscall.SIGHUP after changing the cgroup limits with
+// `systemctl set-property`. Re-evaluations are logged, refresh
+// EffectiveCPUs, and update GOMAXPROCS as when applying the Controller again,
+// including ending an override.
+//
+// Set only handles sig if it succeeds, and the undo function stops handling
+// it, as resetting or closing a Controller does. A Controller ignores sig
+// while another one is in charge of GOMAXPROCS. By default, or if sig is
+// nil, Set doesn't handle signals.
+func ReevaluateOnSignal(sig os.Signal) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.signal = sig
+	})
+}
+
+// signalHandler applies a Controller for ReevaluateOnSignal.
+type signalHandler struct {
+	c       *Controller
+	signals chan os.Signal
+	stop    chan struct{}
+}
+
+// handleSignals starts handling the signal of the Controller's config.
+func (c *Controller) handleSignals() *signalHandler {
+	h := &signalHandler{
+		c:       c,
+		signals: make(chan os.Signal, 1),
+		stop:    make(chan struct{}),
+	}
+	_signalNotify(h.signals, c.cfg.signal)
+	go h.run()
+	return h
+}
+
+// close stops handling the signal. The signal doesn't apply the Controller
+// once it returns, unless the Controller is applied again.
+func (h *signalHandler) close() {
+	_signalStop(h.signals)
+	close(h.stop)
+}
+
+func (h *signalHandler) run() {
+	for {
+		select {
+		case <-h.stop:
+			return
+		case sig := <-h.signals:
+			h.reevaluate(sig)
+		}
+	}
+}
+
+func (h *signalHandler) reevaluate(sig os.Signal) {
+	c := h.c
+	c.background(func() {
+		c.mu.Lock()
+		current := c.signals == h
+		c.mu.Unlock()
+		switch {
+		case !current:
+			// The Controller was reset since the signal was received.
+			return
+		case !c.inCharge():
+			c.cfg.log("maxprocs: Ignoring %v signal: another controller is in charge of GOMAXPROCS", sig)
+			return
+		}
+		c.cfg.log("maxprocs: Re-evaluating GOMAXPROCS on %v signal", sig)
+		RefreshEffectiveCPUs()
+		if err := c.apply(c.applyConfig()); err != nil {
+			c.cfg.log("maxprocs: Failed to re-evaluate GOMAXPROCS: %v", err)
+		}
+	})
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+type testSignal string
+
+func (s testSignal) String() string { return string(s) }
+func (testSignal) Signal()          {}
+
+// stubSignals records the channels notified of signals, until stopped.
+type stubSignals struct {
+	mu       sync.Mutex
+	channels map[chan<- os.Signal]os.Signal
+}
+
+func newStubSignals(t *testing.T) *stubSignals {
+	s := &stubSignals{channels: make(map[chan<- os.Signal]os.Signal)}
+	stubs := gostub.Stub(&_signalNotify, func(c chan<- os.Signal, sigs ...os.Signal) {
+		require.Len(t, sigs, 1, "should handle a single signal")
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.channels[c] = sigs[0]
+	})
+	stubs.Stub(&_signalStop, func(c chan<- os.Signal) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.channels, c)
+	})
+	t.Cleanup(stubs.Reset)
+	return s
+}
+
+func (s *stubSignals) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.channels)
+}
+
+func (s *stubSignals) Send(sig os.Signal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, handled := range s.channels {
+		if handled == sig {
+			c <- sig
+		}
+	}
+}
+
+// chanLogger returns a Logger option that sends log lines to the returned
+// channel, for logs from other goroutines.
+func chanLogger() (chan string, Option) {
+	lines := make(chan string, 10)
+	return lines, Logger(func(format string, args ...interface{}) {
+		lines <- fmt.Sprintf(format, args...)
+	})
+}
+
+// waitForLine skips log lines from chanLogger until want.
+func waitForLine(t *testing.T, lines <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if line == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("didn't log %q", want)
+		}
+	}
+}
+
+func TestReevaluateOnSignal(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+	const sig = testSignal("test")
+
+	t.Run("Set", func(t *testing.T) {
+		signals := newStubSignals(t)
+		quota := &stubQuota{quota: 2}
+		lines, logOpt := chanLogger()
+		undo, err := Set(logOpt, quota.option(), ReevaluateOnSignal(sig))
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, "maxprocs: Updating GOMAXPROCS=2: determined from CPU quota", <-lines)
+		assert.Equal(t, 1, signals.Len(), "should handle the signal")
+
+		quota.Set(3, nil)
+		signals.Send(sig)
+		assert.Equal(t, "maxprocs: Re-evaluating GOMAXPROCS on test signal", <-lines)
+		assert.Equal(t, "maxprocs: Updating GOMAXPROCS=3: determined from CPU quota", <-lines)
+		require.Eventually(t, func() bool { return currentMaxProcs() == 3 }, time.Second, time.Millisecond)
+
+		undo()
+		assert.Equal(t, 0, signals.Len(), "undo should stop handling the signal")
+		assert.Equal(t, prev, currentMaxProcs())
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		signals := newStubSignals(t)
+		stubs := gostub.StubFunc(&_effectiveCPUs, 2.0, nil)
+		defer stubs.Reset()
+		quota := &stubQuota{quota: 2}
+		lines, logOpt := chanLogger()
+		undo, err := Set(logOpt, quota.option(), ReevaluateOnSignal(sig))
+		require.NoError(t, err, "Set failed")
+		defer undo()
+		<-lines
+
+		stubs.StubFunc(&_effectiveCPUs, 3.0, nil)
+		quota.Set(0, errors.New("failed"))
+		signals.Send(sig)
+		assert.Equal(t, "maxprocs: Re-evaluating GOMAXPROCS on test signal", <-lines)
+		waitForLine(t, lines, "maxprocs: Failed to re-evaluate GOMAXPROCS: failed")
+		assert.Equal(t, 3.0, EffectiveCPUs(), "should refresh the effective CPUs")
+		assert.Equal(t, 2, currentMaxProcs(), "shouldn't change on errors")
+	})
+
+	t.Run("QuotaRemoved", func(t *testing.T) {
+		signals := newStubSignals(t)
+		quota := &stubQuota{quota: float64(prev + 1)}
+		lines, logOpt := chanLogger()
+		c := NewController(logOpt, quota.option(), stubGoRuntime(GoRuntime{Version: "go1.24.0"}), ReevaluateOnSignal(sig))
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+		<-lines
+		require.Equal(t, prev+1, currentMaxProcs())
+
+		quota.Set(0, nil)
+		signals.Send(sig)
+		assert.Equal(t, "maxprocs: Re-evaluating GOMAXPROCS on test signal", <-lines)
+		assert.Equal(t, fmt.Sprintf("maxprocs: Resetting GOMAXPROCS to %v: CPU quota removed", prev), <-lines)
+		require.Eventually(t, func() bool { return c.Decision().Status == QuotaRemoved }, time.Second, time.Millisecond,
+			"should report the removed quota")
+		assert.Equal(t, prev, c.Decision().GOMAXPROCS, "should report the restored GOMAXPROCS")
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the quota")
+	})
+
+	t.Run("UndoWhileReevaluating", func(t *testing.T) {
+		signals := newStubSignals(t)
+		var (
+			calls    int
+			entered  = make(chan struct{})
+			released = make(chan struct{})
+		)
+		undo, err := Set(optionFunc(func(cfg *config) {
+			cfg.procs = func(iruntime.Config) (iruntime.Result, error) {
+				if calls++; calls > 1 {
+					close(entered)
+					<-released
+				}
+				return iruntime.Result{GOMAXPROCS: calls + 1, Status: iruntime.CPUQuotaUsed, Quota: float64(calls + 1)}, nil
+			}
+		}), ReevaluateOnSignal(sig))
+		require.NoError(t, err, "Set failed")
+		require.Equal(t, 2, currentMaxProcs())
+
+		signals.Send(sig)
+		<-entered
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			undo()
+		}()
+		select {
+		case <-done:
+			t.Fatal("undo should wait for the re-evaluation")
+		case <-time.After(10 * time.Millisecond):
+		}
+		close(released)
+		<-done
+		assert.Equal(t, 0, signals.Len(), "undo should stop handling the signal")
+		assert.Equal(t, prev, currentMaxProcs(), "undo should reset the re-evaluated GOMAXPROCS")
+	})
+
+	t.Run("SetFails", func(t *testing.T) {
+		signals := newStubSignals(t)
+		quota := &stubQuota{err: errors.New("failed")}
+		undo, err := Set(quota.option(), ReevaluateOnSignal(sig))
+		require.Error(t, err)
+		defer undo()
+		assert.Equal(t, 0, signals.Len(), "shouldn't handle the signal if Set failed")
+	})
+
+	t.Run("NoSignal", func(t *testing.T) {
+		signals := newStubSignals(t)
+		undo, err := Set((&stubQuota{quota: 2}).option(), ReevaluateOnSignal(nil))
+		require.NoError(t, err, "Set failed")
+		defer undo()
+		assert.Equal(t, 0, signals.Len(), "shouldn't handle signals by default")
+	})
+
+	t.Run("Controller", func(t *testing.T) {
+		signals := newStubSignals(t)
+		quota := &stubQuota{quota: 2}
+		lines, logOpt := chanLogger()
+		c := NewController(logOpt, quota.option(), ReevaluateOnSignal(sig))
+		defer c.Close()
+
+		require.NoError(t, c.Apply(), "Apply failed")
+		<-lines
+		require.NoError(t, c.Apply(), "Apply failed")
+		<-lines
+		assert.Equal(t, 1, signals.Len(), "applying again shouldn't handle the signal twice")
+
+		require.NoError(t, c.Override(4, 0), "Override failed")
+		<-lines
+		signals.Send(sig)
+		assert.Equal(t, "maxprocs: Re-evaluating GOMAXPROCS on test signal", <-lines)
+		<-lines
+		require.Eventually(t, func() bool { return c.Decision().Status == QuotaUsed }, time.Second, time.Millisecond, "should end the override")
+		assert.Equal(t, 2, currentMaxProcs())
+
+		other := NewController((&stubQuota{quota: 5}).option())
+		require.NoError(t, other.Apply(), "Apply failed")
+		quota.Set(3, nil)
+		signals.Send(sig)
+		assert.Equal(t, "maxprocs: Ignoring test signal: another controller is in charge of GOMAXPROCS", <-lines)
+		require.NoError(t, other.Close(), "Close failed")
+		assert.Equal(t, 2, currentMaxProcs(), "shouldn't re-evaluate while another controller is in charge")
+
+		require.NoError(t, c.Reset(), "Reset failed")
+		assert.Equal(t, 0, signals.Len(), "Reset should stop handling the signal")
+		require.NoError(t, c.Apply(), "Apply failed")
+		assert.Equal(t, 1, signals.Len(), "Apply should handle the signal again")
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import iruntime "go.uber.org/automaxprocs/internal/runtime"
+
+// QuotaSource provides the CPU quota available to the process. Implement it
+// to derive GOMAXPROCS from limits outside of Linux cgroups, such as a file
+// written by a scheduler or the metadata of a sandbox.
+type QuotaSource interface {
+	// CPUQuota returns the CPU quota in CPUs (for example, 2.5 for two and
+	// a half CPUs), and whether a quota is defined.
+	CPUQuota() (float64, bool, error)
+}
+
+// QuotaSourceFunc adapts a function to a QuotaSource.
+type QuotaSourceFunc func() (float64, bool, error)
+
+// CPUQuota calls f.
+func (f QuotaSourceFunc) CPUQuota() (float64, bool, error) {
+	return f()
+}
+
+// Source sets the QuotaSource that Set reads the CPU quota from. By default,
+// Set uses CGroupsSource.
+func Source(src QuotaSource) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.source = src
+	})
+}
+
+// CGroupsSource returns a QuotaSource that reads the CPU quota from the Linux
+// cgroups of the process, using cgroups v2 if available and v1 otherwise. The
+// quota is always undefined in other OSes.
+func CGroupsSource() QuotaSource {
+	return iruntime.CGroupsSource()
+}
+
+// CGroupsV1Source returns a QuotaSource that reads the CPU quota from the
+// cgroups v1 CPU controller of the process.
+func CGroupsV1Source() QuotaSource {
+	return iruntime.CGroupsV1Source()
+}
+
+// CGroupsV2Source returns a QuotaSource that reads the CPU quota from the
+// cgroups v2 hierarchy of the process. The quota is undefined if the system
+// doesn't use cgroups v2.
+func CGroupsV2Source() QuotaSource {
+	return iruntime.CGroupsV2Source()
+}
+
+// DownwardAPIEnvSource returns a QuotaSource that reads the CPU limit of a
+// Kubernetes container from the environment variable name. Both the value of
+// the variable and divisor are Kubernetes CPU quantities, such as "2", "0.5"
+// or "1500m", and the quota is the value times divisor. An empty divisor is
+// the same as "1". The quota is undefined if the variable is unset or empty.
+//
+// To expose the limit with the downward API, use a resourceFieldRef for
+// `limits.cpu` and pass its divisor. Since Kubernetes rounds the projected
+// value up to a whole multiple of the divisor, a divisor of "1m" is the most
+// precise. Containers without a CPU limit see the allocatable CPUs of the
+// node instead.
+//
+// Use FirstDefined to make the limit override or fall back to CGroupsSource,
+// which is useful in sandboxes such as gVisor or Kata Containers whose cgroups
+// don't reflect the limit of the pod.
+func DownwardAPIEnvSource(name, divisor string) QuotaSource {
+	return iruntime.DownwardAPIEnvSource(name, divisor)
+}
+
+// DownwardAPIFileSource returns a QuotaSource that reads the CPU limit of a
+// Kubernetes container from the file at path, such as one projected by a
+// downward API volume under `/etc/podinfo`. The contents of the file and
+// divisor are interpreted as by DownwardAPIEnvSource. The quota is undefined
+// if the file doesn't exist.
+func DownwardAPIFileSource(path, divisor string) QuotaSource {
+	return iruntime.DownwardAPIFileSource(path, divisor)
+}
+
+// FirstDefined returns a QuotaSource that reports the quota of the first of
+// sources that defines one. Sources after it aren't consulted. Errors are
+// returned as soon as a source reports one.
+func FirstDefined(sources ...QuotaSource) QuotaSource {
+	return firstDefined(sources)
+}
+
+type firstDefined []QuotaSource
+
+func (sources firstDefined) CPUQuota() (float64, bool, error) {
+	for _, src := range sources {
+		quota, defined, err := src.CPUQuota()
+		if err != nil || defined {
+			return quota, defined, err
+		}
+	}
+	return -1, false, nil
+}
+
+// MinQuota returns a QuotaSource that reports the smallest quota defined by
+// any of sources. All sources are consulted, and errors are returned as soon
+// as a source reports one.
+func MinQuota(sources ...QuotaSource) QuotaSource {
+	return minQuota(sources)
+}
+
+type minQuota []QuotaSource
+
+func (sources minQuota) CPUQuota() (float64, bool, error) {
+	least, leastDefined := -1.0, false
+	for _, src := range sources {
+		quota, defined, err := src.CPUQuota()
+		if err != nil {
+			return -1, false, err
+		}
+		if defined && (!leastDefined || quota < least) {
+			least, leastDefined = quota, true
+		}
+	}
+	return least, leastDefined, nil
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"testing"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fixedQuota(quota float64) QuotaSource {
+	return QuotaSourceFunc(func() (float64, bool, error) {
+		return quota, true, nil
+	})
+}
+
+var (
+	undefinedQuota = QuotaSourceFunc(func() (float64, bool, error) {
+		return -1, false, nil
+	})
+	failingQuota = QuotaSourceFunc(func() (float64, bool, error) {
+		return -1, false, errors.New("failed")
+	})
+)
+
+func TestQuotaSourceChains(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         QuotaSource
+		want        float64
+		wantDefined bool
+		wantErr     bool
+	}{
+		{
+			name: "FirstDefined/empty",
+			src:  FirstDefined(),
+			want: -1,
+		},
+		{
+			name:        "FirstDefined/skips undefined",
+			src:         FirstDefined(undefinedQuota, fixedQuota(3), fixedQuota(1)),
+			want:        3,
+			wantDefined: true,
+		},
+		{
+			name:        "FirstDefined/stops at defined",
+			src:         FirstDefined(fixedQuota(3), failingQuota),
+			want:        3,
+			wantDefined: true,
+		},
+		{
+			name:    "FirstDefined/error",
+			src:     FirstDefined(undefinedQuota, failingQuota, fixedQuota(3)),
+			want:    -1,
+			wantErr: true,
+		},
+		{
+			name: "MinQuota/empty",
+			src:  MinQuota(),
+			want: -1,
+		},
+		{
+			name:        "MinQuota/smallest",
+			src:         MinQuota(fixedQuota(3), undefinedQuota, fixedQuota(1.5), fixedQuota(2)),
+			want:        1.5,
+			wantDefined: true,
+		},
+		{
+			name: "MinQuota/all undefined",
+			src:  MinQuota(undefinedQuota, undefinedQuota),
+			want: -1,
+		},
+		{
+			name:    "MinQuota/error",
+			src:     MinQuota(fixedQuota(1), failingQuota),
+			want:    -1,
+			wantErr: true,
+		},
+		{
+			name:        "nested",
+			src:         MinQuota(fixedQuota(4), FirstDefined(undefinedQuota, fixedQuota(2))),
+			want:        2,
+			wantDefined: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota, defined, err := tt.src.CPUQuota()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, quota)
+			assert.Equal(t, tt.wantDefined, defined)
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	defer iruntime.SetCPUAffinity([]int{0, 1, 2, 3, 4, 5, 6, 7})()
+	prev := currentMaxProcs()
+
+	t.Run("Used", func(t *testing.T) {
+		buf, logOpt := testLogger()
+		undo, err := Set(logOpt, Source(FirstDefined(undefinedQuota, fixedQuota(3))))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 3, currentMaxProcs(), "should change GOMAXPROCS to match source")
+		assert.Contains(t, buf.String(), "determined from CPU quota", "unexpected log output")
+	})
+
+	t.Run("DownwardAPI", func(t *testing.T) {
+		t.Setenv("AUTOMAXPROCS_TEST_CPU_LIMIT", "2500m")
+		undo, err := Set(Source(FirstDefined(
+			DownwardAPIEnvSource("AUTOMAXPROCS_TEST_CPU_LIMIT", ""),
+			DownwardAPIFileSource("/nonexistent", "1m"),
+		)))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, 2, currentMaxProcs(), "should change GOMAXPROCS to match the limit")
+	})
+
+	t.Run("Undefined", func(t *testing.T) {
+		undo, err := Set(Source(undefinedQuota))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, prev, currentMaxProcs(), "shouldn't alter GOMAXPROCS")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		undo, err := Set(Source(failingQuota))
+		defer undo()
+		require.Error(t, err, "Set should have failed")
+		assert.Equal(t, prev, currentMaxProcs(), "shouldn't alter GOMAXPROCS")
+	})
+
+	assert.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+}
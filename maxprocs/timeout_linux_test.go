@@ -0,0 +1,61 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+//go:build linux
+// +build linux
+
+package maxprocs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/automaxprocs/maxprocstest"
+)
+
+// TestTimeoutBlockingFS replaces `cpu.max` with a named pipe, which blocks
+// readers until a writer opens it, like a hung FUSE file system.
+func TestTimeoutBlockingFS(t *testing.T) {
+	prev := currentMaxProcs()
+	cg := maxprocstest.WithCgroupV2Max(t)
+	cpuMax := filepath.Join(cg.Root(), "sys/fs/cgroup/cpu.max")
+	require.NoError(t, os.Remove(cpuMax))
+	require.NoError(t, syscall.Mkfifo(cpuMax, 0o600))
+
+	var d Decision
+	undo, err := Set(Timeout(50*time.Millisecond), Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, TimedOut, d.Status)
+	assert.Equal(t, prev, currentMaxProcs(), "GOMAXPROCS shouldn't change")
+
+	// Unblock the reader and wait for it before the cgroup is removed.
+	f, err := os.OpenFile(cpuMax, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("max 100000\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	_detections.Wait()
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Watch makes Set re-evaluate the CPU quota every interval in the background,
+// and update GOMAXPROCS when it changes, as when a container is resized in
+// place. It also refreshes EffectiveCPUs. Re-evaluations are logged when
+// their outcome differs from the previous one, and use the same options as
+// Set. MinChangeInterval, StabilityWindow and DeadBand limit how often
+// GOMAXPROCS changes.
+//
+// Set doesn't watch if it fails, or if it honors the GOMAXPROCS environment
+// variable, unless the EnvOverride policy is EnvClamp. The undo function
+// stops watching before restoring GOMAXPROCS. A Controller only watches while
+// it owns GOMAXPROCS. By default, or if interval isn't positive, Set doesn't
+// watch.
+func Watch(interval time.Duration) Option {
+	return optionFunc(func(cfg *config) {
+		cfg.watchInterval = interval
+	})
+}
+
+// _watchers counts the watchers that may still be re-evaluating, since
+// closing a watcher doesn't wait for it, so that tests can.
+var _watchers sync.WaitGroup
+
+// watcher re-evaluates the CPU quota for Watch.
+type watcher struct {
+	cfg  *config
+	gate hysteresis
+	// active reports whether the Controller that started the watcher owns
+	// GOMAXPROCS, report records the outcome of each re-evaluation, and
+	// queue queues the changes of GOMAXPROCS.
+	active func() bool
+	report func(Decision)
+	queue  func(procs int)
+	// logs is the log output of the previous re-evaluation.
+	logs string
+
+	mu sync.Mutex
+	// stopped is set once close was called.
+	stopped bool
+	stop    chan struct{}
+}
+
+func newWatcher(cfg *config, d Decision, active func() bool, report func(Decision), queue func(procs int)) *watcher {
+	w := &watcher{
+		cfg:    cfg,
+		gate:   cfg.hysteresis,
+		active: active,
+		report: report,
+		queue:  queue,
+		stop:   make(chan struct{}),
+	}
+	w.gate.reset(d.Quota)
+	return w
+}
+
+// start re-evaluates every interval until close is called.
+func (w *watcher) start(interval time.Duration) {
+	_watchers.Add(1)
+	go w.run(interval)
+}
+
+// close stops the watcher. It doesn't wait for an ongoing re-evaluation,
+// which may be the caller, but GOMAXPROCS doesn't change once it returns.
+func (w *watcher) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+	}
+}
+
+// reportUnlessStopped reports d, and whether the watcher wasn't stopped. It
+// holds w.mu, so that nothing is reported once close returns.
+func (w *watcher) reportUnlessStopped(d Decision) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return false
+	}
+	w.report(d)
+	return true
+}
+
+func (w *watcher) run(interval time.Duration) {
+	defer _watchers.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.reevaluate()
+		}
+	}
+}
+
+func (w *watcher) reevaluate() {
+	if !w.active() {
+		return
+	}
+	RefreshEffectiveCPUs()
+
+	logs := &capturedLogs{}
+	cfg := *w.cfg
+	cfg.printf = logs.printf
+	cfg.setProcs = w.setProcs
+
+	d := Decision{GoRuntime: cfg.goRuntime}
+	if _, err := setMaxProcs(&cfg, &d, &w.gate); err != nil {
+		cfg.log("maxprocs: Failed to re-evaluate GOMAXPROCS: %v", err)
+	}
+	d.GOMAXPROCS = currentMaxProcs()
+	if !w.reportUnlessStopped(d) {
+		return
+	}
+
+	// Only log what changed since the previous re-evaluation, rather than
+	// the same outcome every interval.
+	if s := logs.String(); s != w.logs {
+		w.logs = s
+		logs.replay(w.cfg)
+	}
+}
+
+// setProcs sets GOMAXPROCS to procs, or leaves it to the Go runtime if procs
+// is 0, unless the watcher was stopped or its Controller no longer owns
+// GOMAXPROCS.
+func (w *watcher) setProcs(procs int) {
+	w.mu.Lock()
+	if w.stopped || !w.active() {
+		w.mu.Unlock()
+		return
+	}
+	w.queue(procs)
+	w.mu.Unlock()
+
+	// Subscribers may close the watcher, so they're notified without w.mu.
+	_changes.deliver()
+}
+
+// capturedLogs buffers log output. Reads that time out may log after the
+// re-evaluation returns, so it's synchronized.
+type capturedLogs struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+type logEntry struct {
+	format string
+	args   []interface{}
+}
+
+func (l *capturedLogs) printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{format: format, args: args})
+}
+
+func (l *capturedLogs) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var sb strings.Builder
+	for _, e := range l.entries {
+		fmt.Fprintf(&sb, e.format, e.args...)
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func (l *capturedLogs) replay(cfg *config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		cfg.log(e.format, e.args...)
+	}
+}
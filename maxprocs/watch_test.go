@@ -0,0 +1,166 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+
+	"github.com/prashantv/gostub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubQuota is a CPU quota that tests can change while Set watches it.
+type stubQuota struct {
+	mu    sync.Mutex
+	quota float64
+	err   error
+}
+
+func (q *stubQuota) Set(quota float64, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quota, q.err = quota, err
+}
+
+func (q *stubQuota) option() Option {
+	return optionFunc(func(cfg *config) {
+		cfg.procs = func(iruntime.Config) (iruntime.Result, error) {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			if q.err != nil || q.quota <= 0 {
+				return iruntime.Result{GOMAXPROCS: -1, Status: iruntime.CPUQuotaUndefined, Quota: -1}, q.err
+			}
+			return iruntime.Result{GOMAXPROCS: int(q.quota), Status: iruntime.CPUQuotaUsed, Quota: q.quota}, nil
+		}
+	})
+}
+
+func TestWatch(t *testing.T) {
+	prev := currentMaxProcs()
+	defer func() {
+		require.Equal(t, prev, currentMaxProcs(), "didn't undo GOMAXPROCS changes")
+	}()
+
+	stubs := gostub.StubFunc(&_effectiveCPUs, 2.0, nil)
+	defer stubs.Reset()
+	defer _watchers.Wait()
+
+	t.Run("Reevaluate", func(t *testing.T) {
+		clock := newFakeClock()
+		quota := &stubQuota{quota: 2}
+		buf, logOpt := testLogger()
+		cfg := newConfig(logOpt, quota.option(), StabilityWindow(10*time.Second), optionFunc(func(cfg *config) {
+			cfg.hysteresis.now = clock.Now
+		}))
+
+		var d Decision
+		_, err := setMaxProcs(cfg, &d, nil)
+		require.NoError(t, err, "setMaxProcs failed")
+		defer cfg.restoreMaxProcs(prev, false)
+		active := true
+		var last Decision
+		w := newWatcher(cfg, d, func() bool { return active }, func(d Decision) { last = d }, _changes.queue)
+
+		buf.Reset()
+		w.reevaluate()
+		assert.Empty(t, buf.String(), "shouldn't log when nothing changed")
+
+		quota.Set(3, nil)
+		w.reevaluate()
+		assert.Equal(t, 2, currentMaxProcs(), "shouldn't change before the stability window")
+		assert.Equal(t, "maxprocs: Keeping GOMAXPROCS=2: GOMAXPROCS=3 stable for 0s of 10s", buf.String())
+
+		buf.Reset()
+		clock.Add(10 * time.Second)
+		w.reevaluate()
+		assert.Equal(t, 3, currentMaxProcs(), "should change after the stability window")
+		assert.Equal(t, "maxprocs: Updating GOMAXPROCS=3: determined from CPU quota", buf.String())
+		assert.Equal(t, QuotaUsed, last.Status, "should report the re-evaluation")
+		assert.Equal(t, 3, last.GOMAXPROCS, "should report the re-evaluation")
+
+		buf.Reset()
+		quota.Set(0, errors.New("failed"))
+		w.reevaluate()
+		w.reevaluate()
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't change on errors")
+		assert.Equal(t, "maxprocs: Failed to re-evaluate GOMAXPROCS: failed", buf.String(), "should log repeated outcomes once")
+
+		buf.Reset()
+		quota.Set(4, nil)
+		clock.Add(time.Minute)
+		active = false
+		w.reevaluate()
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't change when another controller is in charge")
+		assert.Empty(t, buf.String(), "shouldn't log when another controller is in charge")
+
+		active = true
+		w.close()
+		w.reevaluate()
+		clock.Add(time.Minute)
+		w.reevaluate()
+		assert.Equal(t, 3, currentMaxProcs(), "shouldn't change once closed")
+	})
+
+	t.Run("Set", func(t *testing.T) {
+		quota := &stubQuota{}
+		undo, err := Set(quota.option(), Watch(time.Millisecond))
+		require.NoError(t, err, "Set failed")
+
+		quota.Set(3, nil)
+		assert.Eventually(t, func() bool { return currentMaxProcs() == 3 }, time.Second, time.Millisecond,
+			"should apply the new quota")
+		undo()
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before Set")
+	})
+
+	t.Run("QuotaRemoved", func(t *testing.T) {
+		quota := &stubQuota{}
+		c := NewController(quota.option(), stubGoRuntime(GoRuntime{Version: "go1.24.0"}), Watch(time.Millisecond))
+		defer c.Close()
+		require.NoError(t, c.Apply(), "Apply failed")
+
+		quota.Set(float64(prev+1), nil)
+		require.Eventually(t, func() bool { return currentMaxProcs() == prev+1 }, time.Second, time.Millisecond,
+			"should apply the new quota")
+		quota.Set(0, nil)
+		require.Eventually(t, func() bool { return c.Decision().Status == QuotaRemoved }, time.Second, time.Millisecond,
+			"should report the removed quota")
+		assert.Equal(t, prev, c.Decision().GOMAXPROCS, "should report the restored GOMAXPROCS")
+		assert.Equal(t, prev, currentMaxProcs(), "should restore GOMAXPROCS from before the quota")
+	})
+
+	t.Run("EnvHonored", func(t *testing.T) {
+		t.Setenv(_maxProcsKey, "2")
+		quota := &stubQuota{quota: 3}
+		undo, err := Set(quota.option(), Watch(time.Millisecond))
+		require.NoError(t, err, "Set failed")
+		defer undo()
+
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, prev, currentMaxProcs(), "shouldn't watch when honoring GOMAXPROCS")
+	})
+}
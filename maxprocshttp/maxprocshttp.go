@@ -0,0 +1,402 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package maxprocshttp serves a debug page for a maxprocs.Controller, to
+// inspect and override GOMAXPROCS during incidents without restarting the
+// process. Mount it with, for example:
+//
+//	c := maxprocs.NewController(maxprocs.Logger(log.Printf))
+//	if err := c.Apply(); err != nil {
+//		log.Print(err)
+//	}
+//	http.Handle("/debug/automaxprocs", maxprocshttp.NewHandler(c, maxprocshttp.BearerToken(token)))
+//
+// GET requests render what the Controller decided, the CPU and memory limits
+// it detects, and the recent GOMAXPROCS changes, as HTML or, if the request
+// accepts application/json or has format=json in its query, as JSON.
+//
+// POST requests change GOMAXPROCS, and are only accepted if enabled with
+// BearerToken or Authorize. Their form values select an action:
+//
+//	action=override&gomaxprocs=4&duration=10m
+//		Override GOMAXPROCS, for the optional duration. See
+//		maxprocs.Controller.Override.
+//	action=detect
+//		Apply the Controller again, which re-detects the CPU quota and ends
+//		any override.
+//
+// Successful POST requests are answered like GET requests if they accept
+// JSON, and redirected to the page otherwise.
+//
+// This package is separate from maxprocs so that programs that don't serve
+// it don't depend on net/http.
+package maxprocshttp // import "go.uber.org/automaxprocs/maxprocshttp"
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/automaxprocs/maxprocs"
+)
+
+// An Option configures the handler returned by NewHandler.
+type Option interface {
+	apply(*handler)
+}
+
+type optionFunc func(*handler)
+
+func (of optionFunc) apply(h *handler) { of(h) }
+
+// Authorize enables POST requests, accepting those for which allow returns
+// true. If allow relies on cookies, it must reject cross-site requests. By
+// default, POST requests are rejected.
+func Authorize(allow func(*http.Request) bool) Option {
+	return optionFunc(func(h *handler) {
+		h.allow, h.bearer = allow, false
+	})
+}
+
+// BearerToken enables POST requests with an "Authorization: Bearer <token>"
+// header. Since browsers can't send it from a form, the HTML page shows curl
+// commands instead. An empty token is ignored.
+func BearerToken(token string) Option {
+	return optionFunc(func(h *handler) {
+		if token == "" {
+			return
+		}
+		want := []byte("Bearer " + token)
+		h.allow = func(r *http.Request) bool {
+			return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) == 1
+		}
+		h.bearer = true
+	})
+}
+
+type handler struct {
+	c *maxprocs.Controller
+	// allow authorizes POST requests, which are rejected if it's nil. bearer
+	// is set if it checks a bearer token.
+	allow  func(*http.Request) bool
+	bearer bool
+}
+
+// NewHandler returns an http.Handler that serves the debug page of c.
+func NewHandler(c *maxprocs.Controller, opts ...Option) http.Handler {
+	h := &handler{c: c}
+	for _, o := range opts {
+		o.apply(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.render(w, r)
+	case http.MethodPost:
+		h.post(w, r)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) post(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case h.allow == nil:
+		http.Error(w, "POST requests are disabled", http.StatusForbidden)
+		return
+	case !h.allow(r):
+		if h.bearer {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var err error
+	switch action := r.FormValue("action"); action {
+	case "override":
+		err = h.override(r)
+	case "detect":
+		err = h.c.Apply()
+	default:
+		err = badRequest("invalid action %q: must be override or detect", action)
+	}
+
+	var badReq badRequestError
+	switch {
+	case errors.As(err, &badReq):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, maxprocs.ErrControllerBusy), errors.Is(err, maxprocs.ErrControllerClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case acceptsJSON(r):
+		h.render(w, r)
+	default:
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+	}
+}
+
+func (h *handler) override(r *http.Request) error {
+	procs, err := strconv.Atoi(r.FormValue("gomaxprocs"))
+	if err != nil || procs < 1 {
+		return badRequest("invalid gomaxprocs %q: must be a positive integer", r.FormValue("gomaxprocs"))
+	}
+	var d time.Duration
+	if v := r.FormValue("duration"); v != "" {
+		if d, err = time.ParseDuration(v); err != nil || d < 0 {
+			return badRequest("invalid duration %q: must be a non-negative duration such as 10m", v)
+		}
+	}
+	return h.c.Override(procs, d)
+}
+
+type badRequestError string
+
+func badRequest(format string, args ...interface{}) error {
+	return badRequestError(fmt.Sprintf(format, args...))
+}
+
+func (e badRequestError) Error() string { return string(e) }
+
+func acceptsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func (h *handler) render(w http.ResponseWriter, r *http.Request) {
+	s := h.state()
+	w.Header().Set("Cache-Control", "no-store")
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(s)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = _page.Execute(w, page{
+		state:   s,
+		Path:    r.URL.Path,
+		URL:     requestURL(r),
+		CanPost: h.allow != nil && !h.bearer,
+		Bearer:  h.bearer,
+	})
+}
+
+// requestURL returns the URL r was sent to, for the curl commands of the page.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// state is the JSON representation of the page.
+type state struct {
+	GOMAXPROCS int       `json:"gomaxprocs"`
+	NumCPU     int       `json:"numCPU"`
+	Decision   decision  `json:"decision"`
+	Facts      *facts    `json:"facts,omitempty"`
+	FactsError string    `json:"factsError,omitempty"`
+	History    []change  `json:"history"`
+	Drifts     int64     `json:"drifts"`
+	GoRuntime  goRuntime `json:"goRuntime"`
+}
+
+type decision struct {
+	Status        string   `json:"status"`
+	GOMAXPROCS    int      `json:"gomaxprocs"`
+	Quota         float64  `json:"quota"`
+	Reserved      float64  `json:"reserved"`
+	CPUs          int      `json:"cpus,omitempty"`
+	WeightedCPUs  float64  `json:"weightedCPUs,omitempty"`
+	PhysicalCores int      `json:"physicalCores,omitempty"`
+	Rounding      string   `json:"rounding"`
+	Env           string   `json:"env"`
+	EnvValue      string   `json:"envValue,omitempty"`
+	Rules         []string `json:"rules,omitempty"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+type facts struct {
+	// Quota, CPUPeriod, CPUSetSize and MemoryLimit are null if undefined.
+	Quota         *float64          `json:"quota"`
+	CPUPeriod     *int              `json:"cpuPeriodMicros"`
+	CPUSetSize    *int              `json:"cpusetSize"`
+	HostCPUs      int               `json:"hostCPUs"`
+	CGroupVersion int               `json:"cgroupVersion"`
+	CGroupPaths   map[string]string `json:"cgroupPaths,omitempty"`
+	MemoryLimit   *int64            `json:"memoryLimit"`
+}
+
+type change struct {
+	Time time.Time `json:"time"`
+	Old  int       `json:"old"`
+	New  int       `json:"new"`
+}
+
+type goRuntime struct {
+	Version        string `json:"version"`
+	ContainerAware bool   `json:"containerAware"`
+	Updates        bool   `json:"updates"`
+}
+
+func (h *handler) state() state {
+	d := h.c.Decision()
+	rounding := d.Rounding
+	if rounding == "" {
+		rounding = "default"
+	}
+	s := state{
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Decision: decision{
+			Status:        d.Status.String(),
+			GOMAXPROCS:    d.GOMAXPROCS,
+			Quota:         d.Quota,
+			Reserved:      d.Reserved,
+			CPUs:          d.CPUs,
+			WeightedCPUs:  d.WeightedCPUs,
+			PhysicalCores: d.PhysicalCores,
+			Rounding:      rounding,
+			Env:           d.Env.String(),
+			EnvValue:      d.EnvValue,
+			Rules:         d.Rules,
+			Warnings:      d.Warnings,
+		},
+		GoRuntime: goRuntime{
+			Version:        d.GoRuntime.Version,
+			ContainerAware: d.GoRuntime.ContainerAware,
+			Updates:        d.GoRuntime.Updates,
+		},
+		History: []change{},
+		Drifts:  maxprocs.DriftCount(),
+	}
+
+	if f, err := h.c.Facts(); err != nil {
+		s.FactsError = err.Error()
+	} else {
+		s.Facts = &facts{
+			HostCPUs:      f.HostCPUs,
+			CGroupVersion: f.CGroupVersion,
+			CGroupPaths:   f.CGroupPaths,
+		}
+		if f.Quota >= 0 {
+			s.Facts.Quota = &f.Quota
+		}
+		if f.CPUPeriod >= 0 {
+			s.Facts.CPUPeriod = &f.CPUPeriod
+		}
+		if f.CPUSetSize >= 0 {
+			s.Facts.CPUSetSize = &f.CPUSetSize
+		}
+		if f.MemoryLimit >= 0 {
+			s.Facts.MemoryLimit = &f.MemoryLimit
+		}
+	}
+
+	for _, c := range maxprocs.History() {
+		s.History = append(s.History, change{Time: c.Time, Old: c.Old, New: c.New})
+	}
+	return s
+}
+
+// page is the data of _page. CanPost is set if browsers can post its forms,
+// and Bearer if POST requests need a bearer token instead.
+type page struct {
+	state
+	Path    string
+	URL     string
+	CanPost bool
+	Bearer  bool
+}
+
+var _page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head><title>automaxprocs</title></head>
+<body>
+<h1>GOMAXPROCS={{.GOMAXPROCS}}</h1>
+<p>{{.NumCPU}} CPUs usable by the process.</p>
+
+<h2>Decision</h2>
+<table>
+<tr><th>Status</th><td>{{.Decision.Status}}</td></tr>
+<tr><th>GOMAXPROCS</th><td>{{.Decision.GOMAXPROCS}}</td></tr>
+<tr><th>Quota</th><td>{{.Decision.Quota}}</td></tr>
+<tr><th>Reserved</th><td>{{.Decision.Reserved}}</td></tr>
+{{with .Decision.CPUs}}<tr><th>CPUs</th><td>{{.}}{{with $.Decision.WeightedCPUs}}, weighing {{.}} by capacity{{end}}{{with $.Decision.PhysicalCores}}, on {{.}} physical cores{{end}}</td></tr>{{end}}
+<tr><th>Rounding</th><td>{{.Decision.Rounding}}</td></tr>
+<tr><th>GOMAXPROCS environment variable</th><td>{{.Decision.Env}}{{with .Decision.EnvValue}} ({{.}}){{end}}</td></tr>
+<tr><th>Go runtime</th><td>{{.GoRuntime.Version}}{{if .GoRuntime.ContainerAware}}, container-aware{{end}}{{if .GoRuntime.Updates}}, updating{{end}}</td></tr>
+<tr><th>Drifts</th><td>{{.Drifts}}</td></tr>
+{{with .Decision.Rules}}<tr><th>Policy rules</th><td>{{range $i, $r := .}}{{if $i}}, {{end}}{{$r}}{{end}}</td></tr>{{end}}
+{{with .Decision.Warnings}}<tr><th>Warnings</th><td>{{range .}}{{.}}<br>{{end}}</td></tr>{{end}}
+</table>
+
+<h2>Limits</h2>
+{{with .Facts}}<table>
+<tr><th>CPU quota</th><td>{{with .Quota}}{{.}}{{else}}undefined{{end}}</td></tr>
+<tr><th>CPU period (µs)</th><td>{{with .CPUPeriod}}{{.}}{{else}}undefined{{end}}</td></tr>
+<tr><th>cpuset size</th><td>{{with .CPUSetSize}}{{.}}{{else}}undefined{{end}}</td></tr>
+<tr><th>Host CPUs</th><td>{{.HostCPUs}}</td></tr>
+<tr><th>Memory limit (bytes)</th><td>{{with .MemoryLimit}}{{.}}{{else}}undefined{{end}}</td></tr>
+<tr><th>cgroups version</th><td>{{if .CGroupVersion}}{{.CGroupVersion}}{{else}}none{{end}}</td></tr>
+{{range $name, $path := .CGroupPaths}}<tr><th>{{$name}}</th><td>{{$path}}</td></tr>
+{{end}}</table>{{else}}<p>Failed to detect limits: {{.FactsError}}</p>{{end}}
+
+<h2>History</h2>
+{{if .History}}<table>
+<tr><th>Time</th><th>From</th><th>To</th></tr>
+{{range .History}}<tr><td>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Old}}</td><td>{{.New}}</td></tr>
+{{end}}</table>{{else}}<p>No changes.</p>{{end}}
+{{if .CanPost}}
+<h2>Actions</h2>
+<form method="post" action="{{.Path}}">
+<input type="hidden" name="action" value="override">
+GOMAXPROCS <input type="number" name="gomaxprocs" min="1" required>
+for <input type="text" name="duration" placeholder="10m">
+<button type="submit">Override</button>
+</form>
+<form method="post" action="{{.Path}}">
+<input type="hidden" name="action" value="detect">
+<button type="submit">Re-detect</button>
+</form>
+{{else if .Bearer}}
+<h2>Actions</h2>
+<p>POST requests need a bearer token, for example:</p>
+<pre>curl -X POST -H "Authorization: Bearer $TOKEN" -d action=override -d gomaxprocs=N -d duration=10m {{.URL}}
+curl -X POST -H "Authorization: Bearer $TOKEN" -d action=detect {{.URL}}</pre>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,234 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocshttp
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/automaxprocs/maxprocs"
+)
+
+func newController(t *testing.T) *maxprocs.Controller {
+	prev := runtime.GOMAXPROCS(0)
+	c := maxprocs.NewController(
+		maxprocs.Logger(func(string, ...interface{}) {}),
+		maxprocs.Source(maxprocs.QuotaSourceFunc(func() (float64, bool, error) { return 1, true, nil })),
+	)
+	t.Cleanup(func() {
+		require.NoError(t, c.Close(), "Close failed")
+		require.Equal(t, prev, runtime.GOMAXPROCS(0), "didn't undo GOMAXPROCS changes")
+	})
+	require.NoError(t, c.Apply(), "Apply failed")
+	return c
+}
+
+func serve(h http.Handler, method, target string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	if form != nil {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeState(t *testing.T, w *httptest.ResponseRecorder) state {
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var s state
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &s), "invalid JSON: %s", w.Body)
+	return s
+}
+
+func TestGet(t *testing.T) {
+	c := newController(t)
+	h := NewHandler(c)
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{
+			serve(h, "GET", "/debug/automaxprocs?format=json", nil, nil),
+			serve(h, "GET", "/debug/automaxprocs", nil, http.Header{"Accept": {"application/json"}}),
+		} {
+			require.Equal(t, http.StatusOK, w.Code)
+			s := decodeState(t, w)
+			assert.Equal(t, runtime.GOMAXPROCS(0), s.GOMAXPROCS)
+			assert.Equal(t, c.Decision().Status.String(), s.Decision.Status)
+			assert.Equal(t, 1.0, s.Decision.Quota)
+			assert.Equal(t, "default", s.Decision.Rounding)
+			require.NotNil(t, s.Facts, "facts error: %v", s.FactsError)
+			require.NotNil(t, s.Facts.Quota)
+			assert.Equal(t, 1.0, *s.Facts.Quota)
+			assert.NotNil(t, s.History)
+			assert.Equal(t, maxprocs.DriftCount(), s.Drifts)
+		}
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		w := serve(h, "GET", "/debug/automaxprocs", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "<h2>Decision</h2>")
+		assert.Contains(t, w.Body.String(), "<tr><th>Status</th><td>"+c.Decision().Status.String()+"</td></tr>")
+		assert.NotContains(t, w.Body.String(), "<form", "forms should only be shown if POST is enabled")
+	})
+
+	t.Run("MethodNotAllowed", func(t *testing.T) {
+		w := serve(h, "DELETE", "/debug/automaxprocs", nil, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, "GET, HEAD, POST", w.Header().Get("Allow"))
+	})
+}
+
+func TestPost(t *testing.T) {
+	c := newController(t)
+	h := NewHandler(c, BearerToken("secret"))
+	auth := http.Header{"Authorization": {"Bearer secret"}, "Accept": {"application/json"}}
+	procs := runtime.GOMAXPROCS(0) + 2
+	override := url.Values{"action": {"override"}, "gomaxprocs": {strconv.Itoa(procs)}}
+
+	t.Run("Disabled", func(t *testing.T) {
+		w := serve(NewHandler(c), "POST", "/", override, auth)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NotEqual(t, procs, runtime.GOMAXPROCS(0), "GOMAXPROCS shouldn't be overridden")
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		for _, header := range []http.Header{
+			nil,
+			{"Authorization": {"Bearer wrong"}},
+			{"Authorization": {"secret"}},
+		} {
+			w := serve(h, "POST", "/", override, header)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		}
+		assert.NotEqual(t, procs, runtime.GOMAXPROCS(0), "GOMAXPROCS shouldn't be overridden")
+	})
+
+	t.Run("Override", func(t *testing.T) {
+		w := serve(h, "POST", "/", override, auth)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		s := decodeState(t, w)
+		assert.Equal(t, procs, s.GOMAXPROCS)
+		assert.Equal(t, "overridden", s.Decision.Status)
+		assert.Equal(t, procs, runtime.GOMAXPROCS(0))
+		require.NotEmpty(t, s.History)
+		assert.Equal(t, procs, s.History[len(s.History)-1].New)
+	})
+
+	t.Run("Detect", func(t *testing.T) {
+		w := serve(h, "POST", "/", url.Values{"action": {"detect"}}, auth)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		s := decodeState(t, w)
+		assert.NotEqual(t, "overridden", s.Decision.Status)
+		assert.Equal(t, c.Decision().GOMAXPROCS, runtime.GOMAXPROCS(0))
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		w := serve(h, "POST", "/debug/automaxprocs", url.Values{"action": {"detect"}}, http.Header{"Authorization": {"Bearer secret"}})
+		assert.Equal(t, http.StatusSeeOther, w.Code)
+		assert.Equal(t, "/debug/automaxprocs", w.Header().Get("Location"))
+	})
+
+	t.Run("BadRequest", func(t *testing.T) {
+		tests := []struct {
+			form url.Values
+			want string
+		}{
+			{url.Values{}, `invalid action ""`},
+			{url.Values{"action": {"undo"}}, `invalid action "undo"`},
+			{url.Values{"action": {"override"}}, `invalid gomaxprocs ""`},
+			{url.Values{"action": {"override"}, "gomaxprocs": {"0"}}, `invalid gomaxprocs "0"`},
+			{url.Values{"action": {"override"}, "gomaxprocs": {"2"}, "duration": {"soon"}}, `invalid duration "soon"`},
+			{url.Values{"action": {"override"}, "gomaxprocs": {"2"}, "duration": {"-1m"}}, `invalid duration "-1m"`},
+		}
+		for _, tt := range tests {
+			w := serve(h, "POST", "/", tt.form, auth)
+			assert.Equal(t, http.StatusBadRequest, w.Code, tt.form.Encode())
+			assert.Contains(t, w.Body.String(), tt.want, tt.form.Encode())
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		closed := maxprocs.NewController()
+		require.NoError(t, closed.Close())
+		w := serve(NewHandler(closed, BearerToken("secret")), "POST", "/", override, auth)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestAuthorize(t *testing.T) {
+	c := newController(t)
+	h := NewHandler(c, Authorize(func(r *http.Request) bool {
+		return r.Header.Get("X-Operator") == "yes"
+	}))
+	detect := url.Values{"action": {"detect"}}
+
+	w := serve(h, "POST", "/", detect, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get("WWW-Authenticate"))
+
+	w = serve(h, "POST", "/", detect, http.Header{"X-Operator": {"yes"}})
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+
+	w = serve(h, "GET", "/", nil, nil)
+	assert.Contains(t, w.Body.String(), "<form", "forms should be shown if POST is enabled")
+	assert.NotContains(t, w.Body.String(), "curl")
+}
+
+func TestBearerTokenPage(t *testing.T) {
+	h := NewHandler(newController(t), BearerToken("secret"))
+	w := serve(h, "GET", "http://example.com/debug/automaxprocs", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "<form", "browsers can't post forms with a bearer token")
+	assert.Contains(t, w.Body.String(),
+		`curl -X POST -H "Authorization: Bearer $TOKEN" -d action=detect http://example.com/debug/automaxprocs`)
+
+	h = NewHandler(newController(t), BearerToken("secret"), Authorize(func(*http.Request) bool { return true }))
+	w = serve(h, "GET", "/", nil, nil)
+	assert.Contains(t, w.Body.String(), "<form", "Authorize should replace the bearer token")
+}
+
+func TestBearerTokenEmpty(t *testing.T) {
+	h := NewHandler(newController(t), BearerToken(""))
+	w := serve(h, "POST", "/", url.Values{"action": {"detect"}}, http.Header{"Authorization": {"Bearer "}})
+	assert.Equal(t, http.StatusForbidden, w.Code, "an empty token shouldn't enable POST requests")
+}
+
+func TestMain(m *testing.M) {
+	if err := os.Unsetenv("GOMAXPROCS"); err != nil {
+		log.Fatalf("Couldn't clear GOMAXPROCS: %v\n", err)
+	}
+	os.Exit(m.Run())
+}
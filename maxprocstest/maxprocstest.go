@@ -0,0 +1,232 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+// Package maxprocstest simulates Linux container CPU limits in tests of code
+// that calls maxprocs.Set.
+//
+// Each WithCgroup* function builds a fake `/proc` and cgroup file system tree
+// in a temporary directory and points maxprocs.Set at it until the test
+// finishes. Set never picks more CPUs than the process may run on, which is
+// 1024 CPUs with a fake tree regardless of the machine running the test,
+// unless WithCPUAffinity sets them.
+//
+// Installing or removing a fake also makes maxprocs.EffectiveCPUs detect the
+// CPU capacity again. Because the fakes are shared by the whole process,
+// tests using this package must not run in parallel. Set still honors the
+// GOMAXPROCS environment variable, so tests should make sure it's unset.
+package maxprocstest // import "go.uber.org/automaxprocs/maxprocstest"
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	iruntime "go.uber.org/automaxprocs/internal/runtime"
+)
+
+const (
+	_cgroupMountPoint = "/sys/fs/cgroup"
+
+	// _defaultCPUs is the number of CPUs the process may run on with a fake
+	// tree, so that the CPU quota isn't capped by the machine running the
+	// test.
+	_defaultCPUs = 1024
+)
+
+// _affinities counts the WithCPUAffinity calls whose tests are still
+// running, which the default CPUs of a fake tree mustn't override.
+var _affinities int
+
+// _v1Mounts lists the cgroups v1 hierarchies in the fake tree, keyed by the
+// directory they're mounted at under _cgroupMountPoint.
+var _v1Mounts = []struct {
+	dir         string
+	controllers []string
+}{
+	{"cpu,cpuacct", []string{"cpu", "cpuacct"}},
+	{"cpuset", []string{"cpuset"}},
+	{"memory", []string{"memory"}},
+	{"pids", []string{"pids"}},
+}
+
+// Cgroup is a fake cgroup hierarchy that the current process belongs to.
+type Cgroup struct {
+	t    testing.TB
+	root string
+	// dirs maps controller names to the directory holding their parameter
+	// files. cgroups v2 uses the same directory for all controllers and
+	// stores it under the empty key.
+	dirs map[string]string
+}
+
+// WithCgroupV1 installs a fake cgroups v1 hierarchy whose CPU controller has
+// the given CFS quota and period, in microseconds. A negative quota leaves the
+// quota undefined.
+func WithCgroupV1(t testing.TB, quotaUs, periodUs int) *Cgroup {
+	t.Helper()
+
+	var mountInfo, procCgroup strings.Builder
+	dirs := make(map[string]string)
+	for i, m := range _v1Mounts {
+		mountPoint := filepath.Join(_cgroupMountPoint, m.dir)
+		fmt.Fprintf(&mountInfo, "%d 1 0:%d / %s rw,nosuid,nodev,noexec,relatime - cgroup cgroup rw,%s\n",
+			i+2, i+2, mountPoint, strings.Join(m.controllers, ","))
+		fmt.Fprintf(&procCgroup, "%d:%s:/\n", len(_v1Mounts)-i, strings.Join(m.controllers, ","))
+		for _, controller := range m.controllers {
+			dirs[controller] = mountPoint
+		}
+	}
+
+	cg := newCgroup(t, mountInfo.String(), procCgroup.String(), dirs)
+	cg.SetParam("cpu.cfs_quota_us", fmt.Sprint(quotaUs))
+	cg.SetParam("cpu.cfs_period_us", fmt.Sprint(periodUs))
+	return cg
+}
+
+// WithCgroupV2Quota installs a fake cgroups v2 hierarchy whose `cpu.max` file
+// holds the given quota and period, in microseconds.
+func WithCgroupV2Quota(t testing.TB, quota, period int) *Cgroup {
+	t.Helper()
+
+	cg := newCgroupV2(t)
+	cg.SetParam("cpu.max", fmt.Sprintf("%d %d", quota, period))
+	return cg
+}
+
+// WithCgroupV2Max installs a fake cgroups v2 hierarchy without a CPU quota.
+func WithCgroupV2Max(t testing.TB) *Cgroup {
+	t.Helper()
+
+	cg := newCgroupV2(t)
+	cg.SetParam("cpu.max", "max 100000")
+	return cg
+}
+
+func newCgroupV2(t testing.TB) *Cgroup {
+	t.Helper()
+
+	mountInfo := fmt.Sprintf("2 1 0:2 / %s rw,nosuid,nodev,noexec,relatime - cgroup2 cgroup2 rw,nsdelegate\n", _cgroupMountPoint)
+	return newCgroup(t, mountInfo, "0::/\n", map[string]string{"": _cgroupMountPoint})
+}
+
+func newCgroup(t testing.TB, mountInfo, procCgroup string, dirs map[string]string) *Cgroup {
+	t.Helper()
+
+	if runtime.GOOS != "linux" {
+		t.Skip("cgroups are only supported on Linux")
+	}
+
+	cg := &Cgroup{
+		t:    t,
+		root: t.TempDir(),
+		dirs: dirs,
+	}
+	cg.writeFile("/proc/self/mountinfo", mountInfo)
+	cg.writeFile("/proc/self/cgroup", procCgroup)
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(cg.root, dir), 0o755); err != nil {
+			t.Fatalf("maxprocstest: create cgroup directory: %v", err)
+		}
+	}
+
+	t.Cleanup(iruntime.SetRoot(cg.root))
+	if _affinities == 0 {
+		cpus := make([]int, _defaultCPUs)
+		for i := range cpus {
+			cpus[i] = i
+		}
+		t.Cleanup(iruntime.SetCPUAffinity(cpus))
+	}
+	return cg
+}
+
+// WithCPUAffinity makes maxprocs.Set behave as if the process may only run on
+// the given CPUs until the test finishes, whether it's called before or after
+// the WithCgroup* functions.
+func WithCPUAffinity(t testing.TB, cpus ...int) {
+	t.Helper()
+
+	restore := iruntime.SetCPUAffinity(cpus)
+	_affinities++
+	t.Cleanup(func() {
+		_affinities--
+		restore()
+	})
+}
+
+// Root returns the directory that holds the fake file system tree.
+func (cg *Cgroup) Root() string {
+	return cg.root
+}
+
+// SetParam writes a cgroup parameter file, such as "cpu.max" or
+// "cpu.cfs_quota_us". With cgroups v1, the file is placed in the hierarchy of
+// the controller named by the part of the name before the first ".".
+func (cg *Cgroup) SetParam(name, value string) {
+	cg.t.Helper()
+
+	dir, ok := cg.dirs[""]
+	if !ok {
+		controller := strings.SplitN(name, ".", 2)[0]
+		if dir, ok = cg.dirs[controller]; !ok {
+			cg.t.Fatalf("maxprocstest: no cgroup controller for parameter %q", name)
+		}
+	}
+	cg.writeFile(filepath.Join(dir, name), value+"\n")
+}
+
+// SetCPUCapacity writes the capacity of a CPU, as reported by the kernel of
+// hybrid and big.LITTLE systems, for the maxprocs.CapacityWeighted option.
+// The largest CPUs of a system have a capacity of 1024.
+func (cg *Cgroup) SetCPUCapacity(cpu, capacity int) {
+	cg.t.Helper()
+
+	cg.writeFile(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpu_capacity", cpu), fmt.Sprintf("%d\n", capacity))
+}
+
+// SetCoreCPUs makes cpus the hardware threads of a single physical core, for
+// the maxprocs.PhysicalCores option.
+func (cg *Cgroup) SetCoreCPUs(cpus ...int) {
+	cg.t.Helper()
+
+	list := make([]string, len(cpus))
+	for i, cpu := range cpus {
+		list[i] = strconv.Itoa(cpu)
+	}
+	for _, cpu := range cpus {
+		cg.writeFile(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/topology/core_cpus_list", cpu), strings.Join(list, ",")+"\n")
+	}
+}
+
+func (cg *Cgroup) writeFile(name, contents string) {
+	cg.t.Helper()
+
+	path := filepath.Join(cg.root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		cg.t.Fatalf("maxprocstest: create directory for %v: %v", name, err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		cg.t.Fatalf("maxprocstest: write %v: %v", name, err)
+	}
+}
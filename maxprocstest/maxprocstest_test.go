@@ -0,0 +1,298 @@
+// Copyright (c) 2026 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package maxprocstest_test
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/automaxprocs/maxprocs"
+	"go.uber.org/automaxprocs/maxprocstest"
+)
+
+func TestWithCgroup(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	defer func() {
+		require.Equal(t, prev, runtime.GOMAXPROCS(0), "didn't undo GOMAXPROCS changes")
+	}()
+
+	tests := []struct {
+		name  string
+		setup func(testing.TB)
+		want  int
+	}{
+		{
+			name:  "v1",
+			setup: func(t testing.TB) { maxprocstest.WithCgroupV1(t, 300000, 100000) },
+			want:  3,
+		},
+		{
+			name:  "v1 undefined",
+			setup: func(t testing.TB) { maxprocstest.WithCgroupV1(t, -1, 100000) },
+			want:  prev,
+		},
+		{
+			name:  "v2 quota",
+			setup: func(t testing.TB) { maxprocstest.WithCgroupV2Quota(t, 250000, 100000) },
+			want:  2,
+		},
+		{
+			name:  "v2 quota above the CPUs of the machine",
+			setup: func(t testing.TB) { maxprocstest.WithCgroupV2Quota(t, 6400000, 100000) },
+			want:  64,
+		},
+		{
+			name:  "v2 max",
+			setup: func(t testing.TB) { maxprocstest.WithCgroupV2Max(t) },
+			want:  prev,
+		},
+		{
+			name: "v2 param overwritten",
+			setup: func(t testing.TB) {
+				maxprocstest.WithCgroupV2Max(t).SetParam("cpu.max", "400000 100000")
+			},
+			want: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.setup(t)
+
+			undo, err := maxprocs.Set()
+			defer undo()
+			require.NoError(t, err, "Set failed")
+			assert.Equal(t, tt.want, runtime.GOMAXPROCS(0))
+		})
+	}
+}
+
+func TestCgroupParams(t *testing.T) {
+	cg := maxprocstest.WithCgroupV1(t, 300000, 100000)
+	cg.SetParam("memory.limit_in_bytes", "1024")
+
+	got, err := os.ReadFile(filepath.Join(cg.Root(), "sys/fs/cgroup/memory/memory.limit_in_bytes"))
+	require.NoError(t, err)
+	assert.Equal(t, "1024\n", string(got))
+}
+
+func TestWithCPUAffinity(t *testing.T) {
+	maxprocstest.WithCgroupV2Quota(t, 400000, 100000)
+	maxprocstest.WithCPUAffinity(t, 2, 3)
+
+	undo, err := maxprocs.Set()
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0), "should be limited by affinity")
+}
+
+func TestCgroupCPUCapacity(t *testing.T) {
+	cg := maxprocstest.WithCgroupV2Quota(t, 400000, 100000)
+	maxprocstest.WithCPUAffinity(t, 0, 1, 2, 3)
+	for cpu := 0; cpu < 4; cpu++ {
+		cg.SetCPUCapacity(cpu, 512)
+	}
+
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.CapacityWeighted(), maxprocs.Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0), "should be limited by the capacity of the CPUs")
+	assert.Equal(t, 4, d.CPUs)
+	assert.Equal(t, 2.0, d.WeightedCPUs)
+}
+
+func TestCgroupCoreCPUs(t *testing.T) {
+	cg := maxprocstest.WithCgroupV2Quota(t, 400000, 100000)
+	maxprocstest.WithCPUAffinity(t, 0, 1, 2, 3)
+	cg.SetCoreCPUs(0, 2)
+	cg.SetCoreCPUs(1, 3)
+
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.PhysicalCores(), maxprocs.Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0), "should be limited by the physical cores")
+	assert.Equal(t, maxprocs.CoresUsed, d.Status)
+	assert.Equal(t, 2, d.PhysicalCores)
+}
+
+func TestWithCgroupCPURequest(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	want := 2
+	if n := runtime.NumCPU(); n < want {
+		want = n
+	}
+
+	t.Run("v1", func(t *testing.T) {
+		maxprocstest.WithCgroupV1(t, -1, 100000).SetParam("cpu.shares", "2048")
+
+		undo, err := maxprocs.Set(maxprocs.CPURequest(1))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, want, runtime.GOMAXPROCS(0))
+	})
+
+	t.Run("v2", func(t *testing.T) {
+		// Kubernetes sets a cpu.weight of 79 for a request of 2 CPUs.
+		maxprocstest.WithCgroupV2Max(t).SetParam("cpu.weight", "79")
+
+		undo, err := maxprocs.Set(maxprocs.CPURequest(1))
+		defer undo()
+		require.NoError(t, err, "Set failed")
+		assert.Equal(t, want, runtime.GOMAXPROCS(0))
+	})
+
+	assert.Equal(t, prev, runtime.GOMAXPROCS(0), "didn't undo GOMAXPROCS changes")
+}
+
+func TestWithCgroupPidsLimit(t *testing.T) {
+	for _, v1 := range []bool{true, false} {
+		name := "v2"
+		if v1 {
+			name = "v1"
+		}
+		t.Run(name, func(t *testing.T) {
+			var cg *maxprocstest.Cgroup
+			if v1 {
+				cg = maxprocstest.WithCgroupV1(t, -1, 100000)
+			} else {
+				cg = maxprocstest.WithCgroupV2Max(t)
+			}
+			cg.SetParam("pids.max", "5000")
+
+			undo, err := maxprocs.Set(maxprocs.ThreadLimit(1000))
+			require.NoError(t, err, "Set failed")
+			prev := debug.SetMaxThreads(10000)
+			debug.SetMaxThreads(prev)
+			undo()
+
+			assert.Equal(t, 4000, prev, "should limit threads below pids.max")
+			assert.Equal(t, 10000, debug.SetMaxThreads(10000), "undo should restore max threads")
+		})
+	}
+}
+
+func TestWithCgroupParseError(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	cg := maxprocstest.WithCgroupV2Max(t)
+	cg.SetParam("cpu.max", "250000 0")
+
+	undo, err := maxprocs.Set(maxprocs.Strict())
+	defer undo()
+	assert.Equal(t, prev, runtime.GOMAXPROCS(0), "GOMAXPROCS shouldn't change")
+	assert.ErrorIs(t, err, maxprocs.ErrInvalidValue)
+
+	var parseErr *maxprocs.ParseError
+	require.True(t, errors.As(err, &parseErr), "expected a *ParseError, got %v", err)
+	assert.Equal(t, filepath.Join(cg.Root(), "sys/fs/cgroup/cpu.max"), parseErr.Path)
+	assert.Equal(t, 1, parseErr.Line)
+	assert.Equal(t, "250000 0", parseErr.Content)
+}
+
+func TestWithCgroupLenient(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	defer func() {
+		require.Equal(t, prev, runtime.GOMAXPROCS(0), "didn't undo GOMAXPROCS changes")
+	}()
+
+	cg := maxprocstest.WithCgroupV1(t, 200000, 100000)
+	f, err := os.OpenFile(filepath.Join(cg.Root(), "proc/self/mountinfo"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("40 1 0:40 / /mnt/fuse rw shared:11 fuse.sshfs\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	_, err = maxprocs.Set()
+	assert.ErrorIs(t, err, maxprocs.ErrInvalidFormat, "malformed lines should fail by default")
+
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.Lenient(), maxprocs.Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0), "should use the quota despite the malformed line")
+	require.Len(t, d.Warnings, 1)
+	assert.Contains(t, d.Warnings[0], "invalid format for MountPoint")
+}
+
+func TestWithCgroupEffectiveCPUs(t *testing.T) {
+	maxprocstest.WithCPUAffinity(t, 0, 1, 2, 3, 4, 5, 6, 7)
+
+	t.Run("quota", func(t *testing.T) {
+		maxprocstest.WithCgroupV2Quota(t, 250000, 100000)
+		assert.Equal(t, 2.5, maxprocs.RefreshEffectiveCPUs())
+		assert.Equal(t, 2, maxprocs.EffectiveCPUCount())
+	})
+
+	t.Run("cpuset", func(t *testing.T) {
+		cg := maxprocstest.WithCgroupV1(t, 600000, 100000)
+		cg.SetParam("cpuset.cpus", "0-2")
+		assert.Equal(t, 3.0, maxprocs.RefreshEffectiveCPUs())
+	})
+
+	t.Run("affinity", func(t *testing.T) {
+		maxprocstest.WithCgroupV2Max(t)
+		assert.Equal(t, 8.0, maxprocs.RefreshEffectiveCPUs())
+	})
+}
+
+func TestWithCgroupRefreshesEffectiveCPUs(t *testing.T) {
+	maxprocstest.WithCPUAffinity(t, 0, 1, 2, 3, 4, 5, 6, 7)
+	maxprocstest.WithCgroupV2Quota(t, 200000, 100000)
+	assert.Equal(t, 2.0, maxprocs.EffectiveCPUs(), "should detect again on setup")
+
+	t.Run("nested", func(t *testing.T) {
+		maxprocstest.WithCgroupV2Quota(t, 400000, 100000)
+		assert.Equal(t, 4.0, maxprocs.EffectiveCPUs(), "should detect again on setup")
+	})
+	assert.Equal(t, 2.0, maxprocs.EffectiveCPUs(), "should detect again on cleanup")
+}
+
+func TestWithCgroupReserve(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	defer func() {
+		require.Equal(t, prev, runtime.GOMAXPROCS(0), "didn't undo GOMAXPROCS changes")
+	}()
+
+	maxprocstest.WithCgroupV2Quota(t, 400000, 100000)
+
+	var d maxprocs.Decision
+	undo, err := maxprocs.Set(maxprocs.ReserveCPUs(0.5), maxprocs.ReserveFraction(0.25), maxprocs.Report(&d))
+	defer undo()
+	require.NoError(t, err, "Set failed")
+	assert.Equal(t, 2, runtime.GOMAXPROCS(0), "should subtract the reservation before rounding")
+	assert.Equal(t, 4.0, d.Quota)
+	assert.Equal(t, 1.5, d.Reserved)
+}
+
+func TestMain(m *testing.M) {
+	if err := os.Unsetenv("GOMAXPROCS"); err != nil {
+		log.Fatalf("Couldn't clear GOMAXPROCS: %v\n", err)
+	}
+	os.Exit(m.Run())
+}